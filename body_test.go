@@ -0,0 +1,46 @@
+package geziyor_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+)
+
+func TestStreamBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>streamed</html>"))
+	}))
+	defer ts.Close()
+
+	var body string
+	var stream io.ReadCloser
+	s := geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartRequestsFunc: func(ctx context.Context, g *geziyor.Geziyor) {
+			req, _ := client.NewRequest(ctx, "GET", ts.URL, nil)
+			req.StreamBody = true
+			g.Do(req, nil)
+		},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			assert.Nil(t, r.HTMLDoc)
+			data, _ := io.ReadAll(r.BodyStream)
+			body = string(data)
+			stream = r.BodyStream
+		},
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(context.Background())
+
+	assert.Equal(t, "<html>streamed</html>", body)
+	assert.Equal(t, 1, s.Responses)
+
+	// Stream is closed after callback
+	_, err := stream.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
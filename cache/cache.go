@@ -71,7 +71,12 @@ func cacheKey(req *http.Request) string {
 // CachedResponse returns the cached http.Response for req if present, and nil
 // otherwise.
 func CachedResponse(c Cache, req *http.Request) (resp *http.Response, err error) {
-	cachedVal, ok := c.Get(cacheKey(req))
+	return cachedResponse(c, req, cacheKey(req))
+}
+
+// cachedResponse returns the cached http.Response stored with key if present, and nil otherwise.
+func cachedResponse(c Cache, req *http.Request, key string) (resp *http.Response, err error) {
+	cachedVal, ok := c.Get(key)
 	if !ok {
 		return
 	}
@@ -91,6 +96,17 @@ type Transport struct {
 	Cache     Cache
 	// If true, responses returned from the cache will be given an extra header, X-From-Cache
 	MarkCachedResponses bool
+	// KeyFunc returns the cache key for requests.
+	// If nil, URL is used, prefixed by method for non GET requests.
+	KeyFunc func(req *http.Request) string
+}
+
+// key returns the cache key for req.
+func (t *Transport) key(req *http.Request) string {
+	if t.KeyFunc != nil {
+		return t.KeyFunc(req)
+	}
+	return cacheKey(req)
 }
 
 // NewTransport returns a new Transport with the
@@ -134,11 +150,11 @@ func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error
 // Every request and its corresponding response are cached.
 // When the same request is seen again, the response is returned without transferring anything from the Internet.
 func (t *Transport) RoundTripDummy(req *http.Request) (resp *http.Response, err error) {
-	cacheKey := cacheKey(req)
+	cacheKey := t.key(req)
 	cacheable := (req.Method == "GET" || req.Method == "HEAD") && req.Header.Get("range") == ""
 	var cachedResp *http.Response
 	if cacheable {
-		cachedResp, err = CachedResponse(t.Cache, req)
+		cachedResp, err = cachedResponse(t.Cache, req, cacheKey)
 	} else {
 		// Need to invalidate an existing value
 		t.Cache.Delete(cacheKey)
@@ -180,11 +196,11 @@ func (t *Transport) RoundTripDummy(req *http.Request) (resp *http.Response, err
 // to give the server a chance to respond with NotModified. If this happens, then the cached Response
 // will be returned.
 func (t *Transport) RoundTripRFC2616(req *http.Request) (resp *http.Response, err error) {
-	cacheKey := cacheKey(req)
+	cacheKey := t.key(req)
 	cacheable := (req.Method == "GET" || req.Method == "HEAD") && req.Header.Get("range") == ""
 	var cachedResp *http.Response
 	if cacheable {
-		cachedResp, err = CachedResponse(t.Cache, req)
+		cachedResp, err = cachedResponse(t.Cache, req, cacheKey)
 	} else {
 		// Need to invalidate an existing value
 		t.Cache.Delete(cacheKey)
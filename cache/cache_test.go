@@ -1475,3 +1475,27 @@ func TestClientTimeout(t *testing.T) {
 		t.Error("client.Do took 2+ seconds, want < 2 seconds")
 	}
 }
+
+func TestKeyFunc(t *testing.T) {
+	resetTest()
+	tp := NewMemoryCacheTransport()
+	tp.KeyFunc = func(req *http.Request) string {
+		return req.URL.Path
+	}
+	client := http.Client{Transport: tp}
+
+	for i, query := range []string{"?a=1&b=2", "?b=2&a=1"} {
+		resp, err := client.Get(s.server.URL + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cached := resp.Header.Get(XFromCache) == "1"; cached != (i == 1) {
+			t.Fatalf("request %d: expected cached to be %v", i, i == 1)
+		}
+	}
+}
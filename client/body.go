@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/toqueteos/geziyor/internal"
+)
+
+// maxBytesReader reads from r up to n bytes and fails with ErrBodyTooLarge if r has more.
+// If warnSize is set, a warning is logged once when more than warnSize bytes are read.
+type maxBytesReader struct {
+	r        io.Reader
+	n        int64
+	warnSize int64
+	read     int64
+	url      string
+}
+
+func (l *maxBytesReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// Probe one more byte to tell whether the limit is exceeded
+		var probe [1]byte
+		n, err := l.r.Read(probe[:])
+		if n > 0 {
+			return 0, ErrBodyTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.warnSize > 0 && l.read <= l.warnSize && l.read+int64(n) > l.warnSize {
+		internal.Logger.Printf("Response body of %s is larger than warn size of %d bytes\n", l.url, l.warnSize)
+	}
+	l.read += int64(n)
+	return n, err
+}
+
+// bodyStream is the Response.BodyStream of streamed requests
+type bodyStream struct {
+	io.Reader
+	io.Closer
+}
+
+// cancelCloser cancels context of request after closing response body
+type cancelCloser struct {
+	io.Closer
+	cancel context.CancelFunc
+}
+
+func (c *cancelCloser) Close() error {
+	err := c.Closer.Close()
+	c.cancel()
+	return err
+}
+
+// progressWriter calls progress after every write
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	total    int64
+	progress func(written, total int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.progress(p.written, p.total)
+	return n, err
+}
+
+// saveBody writes body to a temporary file next to req.SaveTo and returns its path and the number of bytes written.
+// It's renamed to SaveTo after response is accepted, so incomplete or failed downloads aren't left at SaveTo.
+func saveBody(req *Request, body io.Reader, total int64) (string, int64, error) {
+	dir := filepath.Dir(req.SaveTo)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", 0, err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(req.SaveTo)+".*.part")
+	if err != nil {
+		return "", 0, err
+	}
+
+	var w io.Writer = f
+	if req.Progress != nil {
+		w = &progressWriter{w: f, total: total, progress: req.Progress}
+	}
+	written, err := io.Copy(w, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", written, err
+	}
+	return f.Name(), written, nil
+}
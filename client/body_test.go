@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newBodyServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := strings.Repeat("a", 100)
+		if r.URL.Path == "/chunked" {
+			w.Write([]byte(body[:50]))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(body[50:]))
+			return
+		}
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte(body))
+	}))
+}
+
+func TestStreamBody(t *testing.T) {
+	ts := newBodyServer()
+	defer ts.Close()
+
+	c := NewClient(&Options{MaxBodySize: 100, RetryTimes: -1})
+	req, _ := NewRequest(context.Background(), "GET", ts.URL+"/chunked", nil)
+	req.StreamBody = true
+	res, err := c.DoRequest(req)
+	assert.NoError(t, err)
+	assert.Empty(t, res.Body)
+	data, err := io.ReadAll(res.BodyStream)
+	assert.NoError(t, err)
+	assert.Len(t, data, 100)
+	assert.NoError(t, res.BodyStream.Close())
+
+	// Exceeding max size fails while reading stream
+	c = NewClient(&Options{MaxBodySize: 60, RetryTimes: -1})
+	req, _ = NewRequest(context.Background(), "GET", ts.URL+"/chunked", nil)
+	req.StreamBody = true
+	res, err = c.DoRequest(req)
+	assert.NoError(t, err)
+	_, err = io.ReadAll(res.BodyStream)
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+	res.BodyStream.Close()
+}
+
+func TestSaveTo(t *testing.T) {
+	ts := newBodyServer()
+	defer ts.Close()
+	dir := t.TempDir()
+
+	var written, total int64
+	c := NewClient(&Options{MaxBodySize: 100, WarnBodySize: 10, RetryTimes: -1})
+	req, _ := NewRequest(context.Background(), "GET", ts.URL, nil)
+	req.SaveTo = filepath.Join(dir, "data", "file.txt")
+	req.Progress = func(w, t int64) {
+		written, total = w, t
+	}
+	res, err := c.DoRequest(req)
+	assert.NoError(t, err)
+	assert.Equal(t, req.SaveTo, res.SavedTo)
+	assert.Equal(t, int64(100), res.SavedSize)
+	assert.Equal(t, int64(100), written)
+	assert.Equal(t, int64(100), total)
+	data, _ := os.ReadFile(req.SaveTo)
+	assert.Len(t, data, 100)
+
+	// Bodies larger than max size aren't saved
+	for _, path := range []string{"/", "/chunked"} {
+		c = NewClient(&Options{MaxBodySize: 60, RetryTimes: -1})
+		req, _ = NewRequest(context.Background(), "GET", ts.URL+path, nil)
+		req.SaveTo = filepath.Join(dir, "large.txt")
+		_, err = c.DoRequest(req)
+		assert.ErrorIs(t, err, ErrBodyTooLarge)
+		var reqErr *Error
+		assert.ErrorAs(t, err, &reqErr)
+		assert.Equal(t, KindBodyTooLarge, reqErr.Kind)
+	}
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+}
+
+func TestSaveToRetried(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/unavailable" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write([]byte("error page"))
+	}))
+	defer ts.Close()
+	dir := t.TempDir()
+	saveTo := filepath.Join(dir, "file.txt")
+	os.WriteFile(saveTo, []byte("previous"), 0644)
+
+	// Failed responses don't replace existing file
+	c := NewClient(&Options{MaxBodySize: 100, RetryTimes: -1, RetryHTTPCodes: []int{503}})
+	req, _ := NewRequest(context.Background(), "GET", ts.URL+"/unavailable", nil)
+	req.SaveTo = saveTo
+	_, err := c.DoRequest(req)
+	var reqErr *Error
+	assert.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, KindHTTPStatus, reqErr.Kind)
+
+	var savedTo string
+	c = NewClient(&Options{MaxBodySize: 100, RetryTimes: -1, RetryPredicate: func(r *Response) bool {
+		savedTo = r.SavedTo
+		data, _ := os.ReadFile(r.SavedTo)
+		return string(data) == "error page"
+	}})
+	req, _ = NewRequest(context.Background(), "GET", ts.URL, nil)
+	req.SaveTo = saveTo
+	_, err = c.DoRequest(req)
+	assert.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, KindRetryPredicate, reqErr.Kind)
+	assert.NotEqual(t, saveTo, savedTo)
+
+	data, _ := os.ReadFile(saveTo)
+	assert.Equal(t, "previous", string(data))
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+}
+
+func TestStreamBodyTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/no-headers" {
+			time.Sleep(300 * time.Millisecond)
+		}
+		for i := 0; i < 5; i++ {
+			w.Write([]byte("aa"))
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer ts.Close()
+	dir := t.TempDir()
+
+	// Client timeout is only applied until response headers of streamed and saved bodies
+	c := NewClient(&Options{MaxBodySize: 100, RetryTimes: -1})
+	c.Client.Timeout = 100 * time.Millisecond
+	req, _ := NewRequest(context.Background(), "GET", ts.URL, nil)
+	req.StreamBody = true
+	res, err := c.DoRequest(req)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(res.BodyStream)
+	assert.NoError(t, err)
+	assert.Len(t, data, 10)
+	res.BodyStream.Close()
+
+	req, _ = NewRequest(context.Background(), "GET", ts.URL, nil)
+	req.SaveTo = filepath.Join(dir, "file.txt")
+	res, err = c.DoRequest(req)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), res.SavedSize)
+
+	req, _ = NewRequest(context.Background(), "GET", ts.URL+"/no-headers", nil)
+	req.SaveTo = filepath.Join(dir, "file.txt")
+	_, err = c.DoRequest(req)
+	var reqErr *Error
+	assert.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, KindTimeout, reqErr.Kind)
+}
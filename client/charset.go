@@ -0,0 +1,163 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/saintfish/chardet"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// EncodingSource is where the encoding of a response body is found
+type EncodingSource string
+
+const (
+	// EncodingSourceRequest is Request.Encoding
+	EncodingSourceRequest EncodingSource = "request"
+	// EncodingSourceHeader is the charset parameter of Content-Type header
+	EncodingSourceHeader EncodingSource = "header"
+	// EncodingSourceBOM is the byte order mark at the start of body
+	EncodingSourceBOM EncodingSource = "bom"
+	// EncodingSourceMeta is <meta charset> or <meta http-equiv="Content-Type"> tag of HTML
+	EncodingSourceMeta EncodingSource = "meta"
+	// EncodingSourceXML is the encoding attribute of XML declaration
+	EncodingSourceXML EncodingSource = "xml"
+	// EncodingSourceDetected means encoding is guessed from body bytes, see DetectEncoding
+	EncodingSourceDetected EncodingSource = "detected"
+)
+
+// prescanSize is how many bytes are searched for meta tags and XML declarations
+const prescanSize = 1024
+
+// minDetectConfidence is the lowest confidence of statistical detection, from 0 to 100, that its result is used
+const minDetectConfidence = 20
+
+var (
+	boms = []struct {
+		bom  []byte
+		name string
+		enc  encoding.Encoding
+	}{
+		{[]byte{0xEF, 0xBB, 0xBF}, "utf-8", unicode.UTF8},
+		{[]byte{0xFE, 0xFF}, "utf-16be", unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)},
+		{[]byte{0xFF, 0xFE}, "utf-16le", unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)},
+	}
+	metaCharsetRe     = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_:.-]+)`)
+	xmlDeclarationRe  = regexp.MustCompile(`^\s*<\?xml[^>]+encoding\s*=\s*["']([A-Za-z0-9_:.-]+)["']`)
+	textualMediaTypes = []string{"xml", "html", "json", "javascript"}
+)
+
+// isText reports whether body of content type is textual, so that its charset can be decoded.
+// If content type is empty or invalid, it's sniffed from body.
+func isText(contentType string, body []byte) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	for _, textual := range textualMediaTypes {
+		if strings.Contains(mediaType, textual) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectEncoding returns the encoding of body by looking at, in order:
+// charset of contentType, byte order mark, <meta> tags and XML declaration.
+// If none is found, body is detected as UTF-8 if it's valid UTF-8. Otherwise its encoding is guessed statistically
+// by byte and character frequencies, like Shift_JIS or windows-1251, falling back to windows-1252 if it's uncertain.
+// name is the canonical name of the encoding.
+func DetectEncoding(body []byte, contentType string) (enc encoding.Encoding, name string, source EncodingSource) {
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		if enc, name := charset.Lookup(params["charset"]); enc != nil {
+			return enc, name, EncodingSourceHeader
+		}
+	}
+
+	for _, bom := range boms {
+		if bytes.HasPrefix(body, bom.bom) {
+			return bom.enc, bom.name, EncodingSourceBOM
+		}
+	}
+
+	head := body
+	if len(head) > prescanSize {
+		head = head[:prescanSize]
+	}
+	if m := metaCharsetRe.FindSubmatch(head); m != nil {
+		if enc, name := charset.Lookup(string(m[1])); enc != nil {
+			return enc, name, EncodingSourceMeta
+		}
+	}
+	if m := xmlDeclarationRe.FindSubmatch(head); m != nil {
+		if enc, name := charset.Lookup(string(m[1])); enc != nil {
+			return enc, name, EncodingSourceXML
+		}
+	}
+
+	if utf8.Valid(body) {
+		return unicode.UTF8, "utf-8", EncodingSourceDetected
+	}
+	if result, err := chardet.NewTextDetector().DetectBest(body); err == nil && result.Confidence >= minDetectConfidence {
+		// Detector names some encodings differently, like "GB-18030"
+		for _, label := range []string{result.Charset, strings.ReplaceAll(result.Charset, "-", "")} {
+			if enc, name := charset.Lookup(label); enc != nil && name != "utf-8" {
+				return enc, name, EncodingSourceDetected
+			}
+		}
+	}
+	return charmap.Windows1252, "windows-1252", EncodingSourceDetected
+}
+
+// decodeBody decodes body of textual responses to UTF-8 and records the encoding. Other bodies are left as is.
+// Request.Encoding is used if it's a known encoding, otherwise the encoding is detected unless charset detection is disabled.
+func (c *Client) decodeBody(res *Response) error {
+	var (
+		enc    encoding.Encoding
+		name   string
+		source EncodingSource
+	)
+	contentType := res.Header.Get("Content-Type")
+	if !isText(contentType, res.Body) {
+		// Binary bodies are never decoded, even if request has an encoding, e.g. copied by Follow
+		return nil
+	}
+	if enc, name = charset.Lookup(res.Request.Encoding); enc != nil {
+		source = EncodingSourceRequest
+	} else if !c.opt.CharsetDetectDisabled {
+		enc, name, source = DetectEncoding(res.Body, contentType)
+	} else {
+		return nil
+	}
+
+	body := res.Body
+	for _, bom := range boms {
+		if bytes.HasPrefix(body, bom.bom) {
+			body = body[len(bom.bom):]
+			break
+		}
+	}
+	if name != "utf-8" {
+		decoded, err := enc.NewDecoder().Bytes(body)
+		if err != nil {
+			return fmt.Errorf("decoding body from %s: %w", name, err)
+		}
+		body = decoded
+	}
+
+	res.Body = body
+	res.Encoding = name
+	res.EncodingSource = source
+	return nil
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		want        string
+		source      EncodingSource
+	}{
+		{"Header", `<meta charset="utf-8">`, "text/html; charset=ISO-8859-9", "windows-1254", EncodingSourceHeader},
+		{"BOM", "\xFF\xFEa\x00", "text/plain", "utf-16le", EncodingSourceBOM},
+		{"Meta", `<html><head><meta charset="shift_jis"></head>`, "text/html", "shift_jis", EncodingSourceMeta},
+		{"MetaHTTPEquiv", `<meta http-equiv="Content-Type" content="text/html; charset=koi8-r">`, "", "koi8-r", EncodingSourceMeta},
+		{"XML", `<?xml version="1.0" encoding="ISO-8859-2"?><rss/>`, "application/rss+xml", "iso-8859-2", EncodingSourceXML},
+		{"UTF8", "Merhaba dünya", "text/plain", "utf-8", EncodingSourceDetected},
+		{"Windows1252", "Caf\xe9", "text/plain", "windows-1252", EncodingSourceDetected},
+		{"ShiftJIS", encode(japanese.ShiftJIS, "<html><body><p>東京都の天気予報です。今日は晴れのち曇り、明日は雨が降るでしょう。</p></body></html>"), "text/html", "shift_jis", EncodingSourceDetected},
+		{"Windows1251", encode(charmap.Windows1251, "<html><body><p>Сегодня в Москве ожидается солнечная погода, без осадков. Завтра будет дождь.</p></body></html>"), "text/html", "windows-1251", EncodingSourceDetected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, name, source := DetectEncoding([]byte(tt.body), tt.contentType)
+			assert.Equal(t, tt.want, name)
+			assert.Equal(t, tt.source, source)
+		})
+	}
+}
+
+func encode(enc encoding.Encoding, s string) string {
+	encoded, _ := enc.NewEncoder().String(s)
+	return encoded
+}
+
+func TestDecodeBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chunked":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><meta charset="iso-8859-9"></head><body>`))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("\xdei\xfeli</body></html>"))
+		case "/bom":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("\xEF\xBB\xBFhello"))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\xe9"))
+		case "/untyped":
+			// Content-Type isn't set by server, as it's written before body
+			w.Header()["Content-Type"] = nil
+			w.Write([]byte("\x1f\x8b\x08\x00\xe9\xff"))
+		case "/undeclared":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(encode(japanese.ShiftJIS, "<html><body>東京都の天気予報です。今日は晴れのち曇り、明日は雨が降るでしょう。</body></html>")))
+		}
+	}))
+	defer ts.Close()
+
+	c := NewClient(&Options{MaxBodySize: DefaultMaxBody, RetryTimes: -1})
+	get := func(path string) *Response {
+		req, _ := NewRequest(context.Background(), "GET", ts.URL+path, nil)
+		res, err := c.DoRequest(req)
+		assert.NoError(t, err)
+		return res
+	}
+
+	res := get("/chunked")
+	assert.Equal(t, int64(-1), res.ContentLength)
+	assert.Contains(t, string(res.Body), "Şişli")
+	assert.Equal(t, "windows-1254", res.Encoding)
+	assert.Equal(t, EncodingSourceMeta, res.EncodingSource)
+
+	res = get("/bom")
+	assert.Equal(t, "hello", string(res.Body))
+	assert.Equal(t, EncodingSourceBOM, res.EncodingSource)
+
+	res = get("/image")
+	assert.Equal(t, "\x89PNG\xe9", string(res.Body))
+	assert.Empty(t, res.Encoding)
+
+	// Request encoding isn't applied to binary bodies
+	req, _ := NewRequest(context.Background(), "GET", ts.URL+"/image", nil)
+	req.Encoding = "iso-8859-9"
+	res, err := c.DoRequest(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "\x89PNG\xe9", string(res.Body))
+	assert.Empty(t, res.Encoding)
+
+	// Binary body without Content-Type is sniffed and isn't decoded
+	res = get("/untyped")
+	assert.Empty(t, res.Header.Get("Content-Type"))
+	assert.Equal(t, "\x1f\x8b\x08\x00\xe9\xff", string(res.Body))
+	assert.Empty(t, res.Encoding)
+
+	res = get("/undeclared")
+	assert.Contains(t, string(res.Body), "東京都の天気予報")
+	assert.Equal(t, "shift_jis", res.Encoding)
+	assert.Equal(t, EncodingSourceDetected, res.EncodingSource)
+}
@@ -15,8 +15,6 @@ import (
 	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
 	"github.com/toqueteos/geziyor/internal"
-	"golang.org/x/net/html/charset"
-	"golang.org/x/text/transform"
 )
 
 var (
@@ -32,13 +30,19 @@ type Client struct {
 
 // Options is custom http.client options
 type Options struct {
-	MaxBodySize           int64
+	MaxBodySize int64
+	// A warning is logged when response bodies are larger than this. Default: No warning
+	WarnBodySize          int64
 	CharsetDetectDisabled bool
 	RetryTimes            int
 	RetryHTTPCodes        []int
-	RemoteAllocatorURL    string
-	AllocatorOptions      []chromedp.ExecAllocatorOption
-	ProxyFunc             func(*http.Request) (*url.URL, error)
+	// Default: BackoffRetryPolicy with RetryTimes
+	RetryPolicy RetryPolicy
+	// Responses are retried if it returns true. Request.RetryPredicate overrides this.
+	RetryPredicate     func(*Response) bool
+	RemoteAllocatorURL string
+	AllocatorOptions   []chromedp.ExecAllocatorOption
+	ProxyFunc          func(*http.Request) (*url.URL, error)
 	// Changing this will override the existing default PreActions for Rendered requests.
 	// Geziyor Response will be nearly empty. Because we have no way to extract response without default pre actions.
 	// So, if you set this, you should handle all navigation, header setting, and response handling yourself.
@@ -54,7 +58,7 @@ const (
 )
 
 var (
-	DefaultRetryHTTPCodes = []int{500, 502, 503, 504, 522, 524, 408}
+	DefaultRetryHTTPCodes = []int{500, 502, 503, 504, 522, 524, 408, 429}
 )
 
 // NewClient creates http.Client with modified values for typical web scraper
@@ -83,6 +87,10 @@ func NewClient(opt *Options) *Client {
 		Timeout: time.Second * 10, // Google's timeout
 	}
 
+	if opt.RetryPolicy == nil {
+		opt.RetryPolicy = &BackoffRetryPolicy{RetryTimes: opt.RetryTimes}
+	}
+
 	client := Client{
 		Client: httpClient,
 		opt:    opt,
@@ -91,79 +99,166 @@ func NewClient(opt *Options) *Client {
 	return &client
 }
 
-// DoRequest selects appropriate request handler, client or Chrome
-func (c *Client) DoRequest(req *Request) (resp *Response, err error) {
+// DoRequest makes request and retries it according to RetryPolicy, waiting between attempts.
+// Returned errors are of type *Error.
+func (c *Client) DoRequest(req *Request) (*Response, error) {
+	for {
+		resp, err := c.DoRequestOnce(req)
+		if err == nil {
+			return resp, nil
+		}
+		delay, retry := c.Retry(req, err)
+		if !retry {
+			return nil, err
+		}
+		req.RetryCountInc()
+		internal.Logger.Println("Retrying:", req.URL.String(), err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// DoRequestOnce selects appropriate request handler, client or Chrome, and makes a single attempt.
+// Responses with RetryHTTPCodes are returned as KindHTTPStatus errors
+// and responses matched by retry predicate are returned as KindRetryPredicate errors.
+// Returned errors are of type *Error.
+func (c *Client) DoRequestOnce(req *Request) (*Response, error) {
+	var resp *Response
+	var err error
 	if req.Rendered {
 		resp, err = c.doRequestChrome(req)
 	} else {
 		resp, err = c.doRequestClient(req)
 	}
-
-	// Retry on Error
 	if err != nil {
-		if req.RetryCount() < c.opt.RetryTimes {
-			req.RetryCountInc()
-			internal.Logger.Println("Retrying:", req.URL.String())
-			return c.DoRequest(req)
-		}
-		return nil, err
+		return nil, NewError(req, nil, err)
 	}
 
-	// Retry on http status codes
-	if internal.ContainsInt(c.opt.RetryHTTPCodes, resp.StatusCode) {
-		if req.RetryCount() < c.opt.RetryTimes {
-			req.RetryCountInc()
-			internal.Logger.Println("Retrying:", req.URL.String(), resp.StatusCode)
-			return c.DoRequest(req)
-		}
-		return nil, fmt.Errorf("error due to status code %d", resp.StatusCode)
+	if c.retryStatus(req, resp.StatusCode) {
+		resp.discardBody()
+		return nil, &Error{Kind: KindHTTPStatus, Request: req, Response: resp, Err: fmt.Errorf("%w %d", ErrHTTPStatus, resp.StatusCode)}
+	}
+
+	retryPredicate := req.RetryPredicate
+	if retryPredicate == nil {
+		retryPredicate = c.opt.RetryPredicate
+	}
+	if retryPredicate != nil && retryPredicate(resp) {
+		resp.discardBody()
+		return nil, &Error{Kind: KindRetryPredicate, Request: req, Response: resp, Err: fmt.Errorf("%w on status code %d", ErrRetryPredicate, resp.StatusCode)}
+	}
+
+	// Saved body is moved to SaveTo only after response is accepted, so retried responses don't replace it
+	if err := resp.commitSavedBody(); err != nil {
+		return nil, NewError(req, resp, fmt.Errorf("saving body: %w", err))
+	}
+	return resp, nil
+}
+
+// retryStatus reports whether responses with statusCode are retried for req
+func (c *Client) retryStatus(req *Request, statusCode int) bool {
+	retryHTTPCodes := req.RetryHTTPCodes
+	if retryHTTPCodes == nil {
+		retryHTTPCodes = c.opt.RetryHTTPCodes
 	}
+	return internal.ContainsInt(retryHTTPCodes, statusCode)
+}
 
-	return resp, err
+// Retry returns whether req should be retried after err returned by DoRequestOnce and the delay before retrying.
+func (c *Client) Retry(req *Request, err error) (time.Duration, bool) {
+	return c.opt.RetryPolicy.Retry(req, NewError(req, nil, err))
 }
 
 // doRequestClient is a simple wrapper to read response according to options.
 func (c *Client) doRequestClient(req *Request) (*Response, error) {
 	// Do request
-	resp, err := c.Do(req.Request)
+	httpClient := c.Client
+	if req.Timeout != 0 {
+		withTimeout := *c.Client
+		withTimeout.Timeout = req.Timeout
+		httpClient = &withTimeout
+	}
+	httpReq := req.Request
+	var headerTimer *time.Timer
+	cancel := context.CancelFunc(func() {})
+	if (req.StreamBody || req.SaveTo != "") && req.Timeout == 0 && httpClient.Timeout != 0 {
+		// Client timeout would cover reading whole body of large downloads, it's only applied until response headers
+		withoutTimeout := *httpClient
+		withoutTimeout.Timeout = 0
+		httpClient = &withoutTimeout
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(req.Context())
+		httpReq = req.Request.WithContext(ctx)
+		headerTimer = time.AfterFunc(c.Client.Timeout, cancel)
+	}
+	start := time.Now()
+	resp, err := httpClient.Do(httpReq)
+	latency := time.Since(start)
+	headerTimeout := headerTimer != nil && !headerTimer.Stop()
+	streaming := false
 	defer func() {
-		if resp != nil {
-			resp.Body.Close()
+		if !streaming {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			cancel()
 		}
 	}()
 	if err != nil {
-		return nil, fmt.Errorf("response: %w", err)
-	}
-
-	// Limit response body reading
-	bodyReader := io.LimitReader(resp.Body, c.opt.MaxBodySize)
-
-	// Decode response
-	if resp.Request.Method != "HEAD" && resp.ContentLength > 0 {
-		if req.Encoding != "" {
-			if enc, _ := charset.Lookup(req.Encoding); enc != nil {
-				bodyReader = transform.NewReader(bodyReader, enc.NewDecoder())
-			}
-		} else {
-			if !c.opt.CharsetDetectDisabled {
-				contentType := req.Header.Get("Content-Type")
-				bodyReader, err = charset.NewReader(bodyReader, contentType)
-				if err != nil {
-					return nil, fmt.Errorf("charset detection error on content-type %s: %w", contentType, err)
-				}
-			}
+		if headerTimeout && req.Context().Err() == nil {
+			return nil, fmt.Errorf("response: awaiting headers: %w", context.DeadlineExceeded)
 		}
-	}
-
-	body, err := io.ReadAll(bodyReader)
-	if err != nil {
-		return nil, fmt.Errorf("reading body: %w", err)
+		return nil, fmt.Errorf("response: %w", err)
 	}
 
 	response := Response{
 		Response: resp,
-		Body:     body,
 		Request:  req,
+		Latency:  latency,
+	}
+	if resp.Request.Method == "HEAD" {
+		return &response, nil
+	}
+
+	// Limit response body reading
+	if resp.ContentLength > c.opt.MaxBodySize {
+		return nil, fmt.Errorf("reading body: %w", ErrBodyTooLarge)
+	}
+	bodyReader := &maxBytesReader{r: resp.Body, n: c.opt.MaxBodySize, warnSize: c.opt.WarnBodySize, url: req.URL.String()}
+
+	switch {
+	case req.StreamBody:
+		streaming = true
+		response.BodyStream = &bodyStream{Reader: bodyReader, Closer: &cancelCloser{Closer: resp.Body, cancel: cancel}}
+	case req.SaveTo != "":
+		if c.retryStatus(req, resp.StatusCode) {
+			// Body of a failed response isn't saved
+			break
+		}
+		part, written, err := saveBody(req, bodyReader, resp.ContentLength)
+		if err != nil {
+			return nil, fmt.Errorf("saving body: %w", err)
+		}
+		response.SavedTo = part
+		response.SavedSize = written
+		response.savedPart = true
+	default:
+		response.Body, err = io.ReadAll(bodyReader)
+		if err != nil {
+			return nil, fmt.Errorf("reading body: %w", err)
+		}
+		// Decode response
+		if len(response.Body) != 0 {
+			if err := c.decodeBody(&response); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return &response, nil
@@ -225,6 +320,7 @@ func (c *Client) doRequestChrome(req *Request) (*Response, error) {
 	defaultPreActions = append(defaultPreActions, req.Actions...)
 
 	// Run all actions
+	start := time.Now()
 	if err := chromedp.Run(taskCtx, defaultPreActions...); err != nil {
 		return nil, fmt.Errorf("request getting rendered: %w", err)
 	}
@@ -246,6 +342,7 @@ func (c *Client) doRequestChrome(req *Request) (*Response, error) {
 		Response: httpResponse,
 		Body:     []byte(body),
 		Request:  req,
+		Latency:  time.Since(start),
 	}
 
 	return &response, nil
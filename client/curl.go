@@ -0,0 +1,301 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// curlIgnoredFlags are curl options without arguments that don't change the request.
+// --compressed is ignored as responses are decompressed transparently.
+var curlIgnoredFlags = map[string]bool{
+	"--compressed": true,
+	"-L":           true,
+	"--location":   true,
+	"-k":           true,
+	"--insecure":   true,
+	"-s":           true,
+	"--silent":     true,
+	"-S":           true,
+	"--show-error": true,
+	"-i":           true,
+	"--include":    true,
+	"-v":           true,
+	"--verbose":    true,
+}
+
+// NewRequestFromCurl returns a request from a curl command, such as the ones copied from browser developer tools.
+// Supported options are -X, -H, -d, --data-raw, --data-binary, --data-urlencode, -G, -b, -u, -A, -e, -m and --url.
+// Options that don't change the request, like --compressed or -L, are ignored. Other options return error.
+// Accept-Encoding header is removed, so that responses are decompressed transparently.
+func NewRequestFromCurl(ctx context.Context, cmd string) (*Request, error) {
+	args, err := splitShellWords(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return nil, errors.New("curl: command must start with curl")
+	}
+
+	var (
+		method, rawURL, user string
+		data                 []string
+		get, hasUser         bool
+		timeout              time.Duration
+		header               = http.Header{}
+	)
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			rawURL = arg
+			continue
+		}
+		if curlIgnoredFlags[arg] {
+			continue
+		}
+		if arg == "-G" || arg == "--get" {
+			get = true
+			continue
+		}
+
+		// Options with an argument, either as next argument or attached to short option like -XPOST
+		name, value := arg, ""
+		if !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			name, value = arg[:2], arg[2:]
+		} else {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("curl: option %s requires an argument", arg)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "-X", "--request":
+			method = value
+		case "-H", "--header":
+			key, val, _ := strings.Cut(value, ":")
+			header.Add(strings.TrimSpace(key), strings.TrimSpace(val))
+		case "-d", "--data", "--data-ascii", "--data-binary":
+			if strings.HasPrefix(value, "@") {
+				return nil, fmt.Errorf("curl: reading data from files is not supported: %s", value)
+			}
+			data = append(data, value)
+		case "--data-raw":
+			data = append(data, value)
+		case "--data-urlencode":
+			data = append(data, curlURLEncode(value))
+		case "-b", "--cookie":
+			if !strings.Contains(value, "=") {
+				return nil, fmt.Errorf("curl: reading cookies from files is not supported: %s", value)
+			}
+			header.Add("Cookie", value)
+		case "-u", "--user":
+			user, hasUser = value, true
+		case "-A", "--user-agent":
+			header.Set("User-Agent", value)
+		case "-e", "--referer":
+			header.Set("Referer", value)
+		case "-m", "--max-time":
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("curl: invalid max time %q", value)
+			}
+			timeout = time.Duration(seconds * float64(time.Second))
+		case "--url":
+			rawURL = value
+		default:
+			return nil, fmt.Errorf("curl: unsupported option %s", arg)
+		}
+	}
+	if rawURL == "" {
+		return nil, errors.New("curl: no URL")
+	}
+
+	var body io.Reader
+	if len(data) != 0 {
+		joined := strings.Join(data, "&")
+		if get {
+			u, err := url.Parse(rawURL)
+			if err != nil {
+				return nil, err
+			}
+			if u.RawQuery != "" {
+				u.RawQuery += "&"
+			}
+			u.RawQuery += joined
+			rawURL = u.String()
+		} else {
+			body = strings.NewReader(joined)
+			if method == "" {
+				method = "POST"
+			}
+			if header.Get("Content-Type") == "" {
+				header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+		}
+	}
+	if method == "" {
+		method = "GET"
+	}
+
+	req, err := NewRequest(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	header.Del("Accept-Encoding")
+	req.Header = header
+	if hasUser {
+		username, password, _ := strings.Cut(user, ":")
+		req.SetBasicAuth(username, password)
+	}
+	req.Timeout = timeout
+	return req, nil
+}
+
+// curlURLEncode encodes --data-urlencode argument: "content", "=content", "name=content"
+func curlURLEncode(value string) string {
+	name, content, found := strings.Cut(value, "=")
+	if !found {
+		return url.QueryEscape(value)
+	}
+	if name == "" {
+		return url.QueryEscape(content)
+	}
+	return name + "=" + url.QueryEscape(content)
+}
+
+// splitShellWords splits command into arguments like a POSIX shell does for quotes and backslashes.
+// Bash $'...' strings are supported as browsers use them to copy requests with special characters.
+func splitShellWords(cmd string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inWord  bool
+	)
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		switch {
+		case c == '\\':
+			if i+1 < len(cmd) {
+				i++
+				// Line continuation
+				if cmd[i] == '\n' || cmd[i] == '\r' {
+					continue
+				}
+				current.WriteByte(cmd[i])
+				inWord = true
+			}
+		case c == '\'':
+			end := strings.IndexByte(cmd[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("curl: unterminated quote")
+			}
+			current.WriteString(cmd[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(cmd) && cmd[i+1] == '\'':
+			n, err := ansiCQuoted(cmd[i+2:], &current)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(cmd) && cmd[i] != '"'; i++ {
+				if cmd[i] == '\\' && i+1 < len(cmd) && strings.IndexByte("$`\"\\\n", cmd[i+1]) >= 0 {
+					i++
+				}
+				current.WriteByte(cmd[i])
+			}
+			if i >= len(cmd) {
+				return nil, errors.New("curl: unterminated quote")
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// ansiCQuoted writes the content of a $'...' string to w and returns the length consumed, including closing quote
+func ansiCQuoted(s string, w *strings.Builder) (int, error) {
+	escapes := map[byte]byte{'n': '\n', 't': '\t', 'r': '\r', '\\': '\\', '\'': '\'', '"': '"'}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			return i + 1, nil
+		case '\\':
+			if i+1 >= len(s) {
+				return 0, errors.New("curl: unterminated quote")
+			}
+			i++
+			if escaped, ok := escapes[s[i]]; ok {
+				w.WriteByte(escaped)
+			} else if s[i] == 'x' && i+2 < len(s) {
+				b, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+				if err != nil {
+					return 0, fmt.Errorf("curl: invalid escape \\x%s", s[i+1:i+3])
+				}
+				w.WriteByte(byte(b))
+				i += 2
+			} else {
+				w.WriteByte('\\')
+				w.WriteByte(s[i])
+			}
+		default:
+			w.WriteByte(s[i])
+		}
+	}
+	return 0, errors.New("curl: unterminated quote")
+}
+
+// Curl returns request as a curl command, for debugging. Body is read without consuming it.
+func (r *Request) Curl() string {
+	var b strings.Builder
+	b.WriteString("curl")
+	if r.Method != "GET" {
+		b.WriteString(" -X " + shellQuote(r.Method))
+	}
+	b.WriteString(" " + shellQuote(r.URL.String()))
+
+	keys := make([]string, 0, len(r.Header))
+	for key := range r.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range r.Header[key] {
+			b.WriteString(" -H " + shellQuote(key+": "+value))
+		}
+	}
+
+	if body, err := requestBody(r.Request); err == nil && len(body) != 0 {
+		b.WriteString(" --data-raw " + shellQuote(string(body)))
+	}
+	return b.String()
+}
+
+// shellQuote quotes s with single quotes for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
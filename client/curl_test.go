@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRequestFromCurl(t *testing.T) {
+	// Copied as cURL (bash) from a browser
+	cmd := `curl 'https://example.com/api/search?page=1' \
+  -H 'accept: application/json' \
+  -H 'accept-encoding: gzip, deflate, br' \
+  -H $'x-note: it\'s \x41' \
+  -b 'session=abc; theme=dark' \
+  -u "user:pa\"ss" \
+  --data-raw '{"q":"books"}' \
+  --compressed -m 2.5`
+	req, err := NewRequestFromCurl(context.Background(), cmd)
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "https://example.com/api/search?page=1", req.URL.String())
+	assert.Equal(t, "application/json", req.Header.Get("Accept"))
+	assert.Equal(t, "", req.Header.Get("Accept-Encoding"))
+	assert.Equal(t, "it's A", req.Header.Get("X-Note"))
+	assert.Equal(t, "session=abc; theme=dark", req.Header.Get("Cookie"))
+	username, password, _ := req.BasicAuth()
+	assert.Equal(t, "user", username)
+	assert.Equal(t, `pa"ss`, password)
+	assert.Equal(t, 2500*time.Millisecond, req.Timeout)
+	body, _ := io.ReadAll(req.Body)
+	assert.Equal(t, `{"q":"books"}`, string(body))
+}
+
+func TestNewRequestFromCurlData(t *testing.T) {
+	req, err := NewRequestFromCurl(context.Background(), `curl -XPUT https://example.com -d a=1 --data-urlencode 'b=x y&z'`)
+	assert.NoError(t, err)
+	assert.Equal(t, "PUT", req.Method)
+	assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(req.Body)
+	assert.Equal(t, "a=1&b=x+y%26z", string(body))
+
+	req, err = NewRequestFromCurl(context.Background(), `curl -G https://example.com/?a=1 -d b=2`)
+	assert.NoError(t, err)
+	assert.Equal(t, "GET", req.Method)
+	assert.Equal(t, "https://example.com/?a=1&b=2", req.URL.String())
+
+	_, err = NewRequestFromCurl(context.Background(), `curl --proxy http://localhost:8080 https://example.com`)
+	assert.Error(t, err)
+}
+
+func TestRequestCurl(t *testing.T) {
+	req, err := NewRequestFromCurl(context.Background(), `curl https://example.com/login -H 'X-Token: 1' --data-raw "name=it's"`)
+	assert.NoError(t, err)
+	cmd := req.Curl()
+	assert.Equal(t, `curl -X 'POST' 'https://example.com/login' -H 'Content-Type: application/x-www-form-urlencoded' -H 'X-Token: 1' --data-raw 'name=it'\''s'`, cmd)
+
+	// Body isn't consumed and command is parsed back to the same request
+	again, err := NewRequestFromCurl(context.Background(), cmd)
+	assert.NoError(t, err)
+	assert.Equal(t, req.Header, again.Header)
+	body, _ := io.ReadAll(again.Body)
+	assert.Equal(t, "name=it's", string(body))
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+)
+
+var (
+	// ErrBodyTooLarge is returned when response body is larger than Options.MaxBodySize
+	ErrBodyTooLarge = errors.New("response body too large")
+
+	// ErrRequestCancelled is returned for requests cancelled by middlewares
+	ErrRequestCancelled = errors.New("request cancelled")
+
+	// ErrHTTPStatus is wrapped by KindHTTPStatus errors
+	ErrHTTPStatus = errors.New("error due to status code")
+
+	// ErrRetryPredicate is wrapped by KindRetryPredicate errors
+	ErrRetryPredicate = errors.New("error due to retry predicate")
+)
+
+// ErrorKind classifies request errors
+type ErrorKind int
+
+const (
+	// KindOther is any error that doesn't fit to other kinds
+	KindOther ErrorKind = iota
+
+	// KindDNS means host name couldn't be resolved
+	KindDNS
+
+	// KindTimeout means request or connection timed out
+	KindTimeout
+
+	// KindTLS means TLS handshake or certificate verification failed
+	KindTLS
+
+	// KindHTTPStatus means response status code is still in RetryHTTPCodes after all retries.
+	// Error.Response is the last response.
+	KindHTTPStatus
+
+	// KindRetryPredicate means retry predicate still reports the response as failed after all retries.
+	// Error.Response is the last response.
+	KindRetryPredicate
+
+	// KindBodyTooLarge means response body is larger than Options.MaxBodySize
+	KindBodyTooLarge
+
+	// KindCancelled means request is cancelled by a middleware or its context
+	KindCancelled
+)
+
+var errorKindNames = map[ErrorKind]string{
+	KindOther:          "other",
+	KindDNS:            "dns",
+	KindTimeout:        "timeout",
+	KindTLS:            "tls",
+	KindHTTPStatus:     "http_status",
+	KindRetryPredicate: "retry_predicate",
+	KindBodyTooLarge:   "body_too_large",
+	KindCancelled:      "cancelled",
+}
+
+func (k ErrorKind) String() string {
+	return errorKindNames[k]
+}
+
+// Error is the error of failed requests
+type Error struct {
+	Kind     ErrorKind
+	Request  *Request
+	Response *Response
+	Err      error
+}
+
+// NewError wraps err of req into *Error and classifies it.
+// If err is already an *Error, it's returned as is.
+func NewError(req *Request, res *Response, err error) *Error {
+	var reqErr *Error
+	if errors.As(err, &reqErr) {
+		return reqErr
+	}
+	return &Error{Kind: errorKind(err), Request: req, Response: res, Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// errorKind finds kind of err by looking its wrapped errors
+func errorKind(err error) ErrorKind {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var recordHeaderErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certInvalidErr x509.CertificateInvalidError
+
+	switch {
+	case errors.Is(err, ErrHTTPStatus):
+		return KindHTTPStatus
+	case errors.Is(err, ErrRetryPredicate):
+		return KindRetryPredicate
+	case errors.Is(err, ErrBodyTooLarge):
+		return KindBodyTooLarge
+	case errors.Is(err, ErrRequestCancelled), errors.Is(err, context.Canceled):
+		return KindCancelled
+	case errors.As(err, &dnsErr):
+		return KindDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return KindTimeout
+	case errors.As(err, &recordHeaderErr), errors.As(err, &alertErr), errors.As(err, &certErr),
+		errors.As(err, &unknownAuthorityErr), errors.As(err, &hostnameErr), errors.As(err, &certInvalidErr):
+		return KindTLS
+	default:
+		return KindOther
+	}
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorKinds(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/status":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/large":
+			fmt.Fprint(w, strings.Repeat("a", 100))
+		}
+	}))
+	defer ts.Close()
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+
+	tests := []struct {
+		url  string
+		kind ErrorKind
+	}{
+		{"http://geziyor.invalid", KindDNS},
+		{ts.URL + "/slow", KindTimeout},
+		{tlsServer.URL, KindTLS},
+		{ts.URL + "/status", KindHTTPStatus},
+		{ts.URL + "/large", KindBodyTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind.String(), func(t *testing.T) {
+			client := NewClient(&Options{
+				MaxBodySize:    50,
+				RetryTimes:     0,
+				RetryHTTPCodes: DefaultRetryHTTPCodes,
+			})
+			if tt.kind == KindTimeout {
+				client.Timeout = 100 * time.Millisecond
+			}
+
+			req, _ := NewRequest(context.Background(), "GET", tt.url, nil)
+			res, err := client.DoRequest(req)
+			assert.Nil(t, res)
+
+			var reqErr *Error
+			if assert.True(t, errors.As(err, &reqErr)) {
+				assert.Equal(t, tt.kind, reqErr.Kind, err.Error())
+				assert.Equal(t, req, reqErr.Request)
+				if tt.kind == KindHTTPStatus {
+					assert.Equal(t, http.StatusServiceUnavailable, reqErr.Response.StatusCode)
+					assert.ErrorIs(t, err, ErrHTTPStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("a", 100))
+	}))
+	defer ts.Close()
+
+	req, _ := NewRequest(context.Background(), "GET", ts.URL, nil)
+	res, err := NewClient(&Options{MaxBodySize: 100}).DoRequest(req)
+	assert.NoError(t, err)
+	assert.Len(t, res.Body, 100)
+
+	req, _ = NewRequest(context.Background(), "GET", ts.URL, nil)
+	_, err = NewClient(&Options{MaxBodySize: 99}).DoRequest(req)
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+}
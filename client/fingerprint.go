@@ -0,0 +1,167 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Fingerprinter identifies requests, so that requests for the same resource can be detected.
+type Fingerprinter interface {
+	Fingerprint(req *http.Request) string
+}
+
+// DefaultIgnoredParams are tracking query parameters that don't change the requested resource.
+var DefaultIgnoredParams = []string{"utm_*", "fbclid", "gclid", "msclkid"}
+
+// DefaultFingerprinter hashes method, canonical URL, selected headers and body of requests.
+// Body is read with Request.GetBody, so request isn't modified. Bodies without GetBody aren't hashed.
+// See CanonicalizeURL for URL canonicalization.
+type DefaultFingerprinter struct {
+	// Query parameters to ignore. Parameters ending with "*" are matched by prefix.
+	// Set to an empty slice to keep all parameters.
+	// Default: DefaultIgnoredParams
+	IgnoredParams []string
+
+	// Request headers included in fingerprints. Default: None
+	Headers []string
+
+	// If true, URL fragments are kept
+	KeepFragments bool
+}
+
+// Fingerprint returns hex encoded SHA1 hash of request
+func (f *DefaultFingerprinter) Fingerprint(req *http.Request) string {
+	ignoredParams := f.IgnoredParams
+	if ignoredParams == nil {
+		ignoredParams = DefaultIgnoredParams
+	}
+
+	h := sha1.New()
+	io.WriteString(h, req.Method)
+	h.Write([]byte{0})
+	io.WriteString(h, CanonicalizeURL(req.URL, ignoredParams, f.KeepFragments).String())
+	h.Write([]byte{0})
+	for _, header := range f.Headers {
+		io.WriteString(h, strings.ToLower(header))
+		h.Write([]byte{':'})
+		io.WriteString(h, strings.Join(req.Header.Values(header), ","))
+		h.Write([]byte{0})
+	}
+	// Fingerprints are taken in RoundTrip of cache transport too, which must not modify request
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			io.Copy(h, body)
+			body.Close()
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// requestBody returns request body without consuming it
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("getting body: %w", err)
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+	return data, nil
+}
+
+// CanonicalizeURL returns a copy of u that is the same for URLs of the same resource:
+// Scheme and host are lowercased, default ports and ignored query parameters are removed,
+// query parameters are sorted and fragment is removed unless keepFragment is true.
+// Ignored parameters ending with "*" are matched by prefix.
+func CanonicalizeURL(u *url.URL, ignoredParams []string, keepFragment bool) *url.URL {
+	canonical := *u
+	canonical.User = nil
+	canonical.Scheme = strings.ToLower(u.Scheme)
+	canonical.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (canonical.Scheme == "http" && port == "80") || (canonical.Scheme == "https" && port == "443") {
+		canonical.Host = strings.TrimSuffix(canonical.Host, ":"+port)
+	}
+	if canonical.Path == "" && canonical.Opaque == "" {
+		canonical.Path = "/"
+	}
+	if !keepFragment {
+		canonical.Fragment = ""
+		canonical.RawFragment = ""
+	}
+	canonical.ForceQuery = false
+	canonical.RawQuery = canonicalQuery(u.RawQuery, ignoredParams)
+	return &canonical
+}
+
+// canonicalQuery sorts query parameters by key and value and removes ignored ones
+func canonicalQuery(rawQuery string, ignoredParams []string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	type param struct{ key, value string }
+	var params []param
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		if isIgnoredParam(key, ignoredParams) {
+			continue
+		}
+		params = append(params, param{key, value})
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		if params[i].key != params[j].key {
+			return params[i].key < params[j].key
+		}
+		return params[i].value < params[j].value
+	})
+
+	var buf strings.Builder
+	for i, p := range params {
+		if i > 0 {
+			buf.WriteByte('&')
+		}
+		buf.WriteString(url.QueryEscape(p.key))
+		buf.WriteByte('=')
+		buf.WriteString(url.QueryEscape(p.value))
+	}
+	return buf.String()
+}
+
+func isIgnoredParam(key string, ignoredParams []string) bool {
+	for _, ignored := range ignoredParams {
+		if prefix, isPrefix := strings.CutSuffix(ignored, "*"); isPrefix {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == ignored {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalizeURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"Sorted query", "http://example.com/?b=2&a=1&a=0", "http://example.com/?a=0&a=1&b=2"},
+		{"Fragment", "http://example.com/page#section", "http://example.com/page"},
+		{"Default port", "https://example.com:443/page", "https://example.com/page"},
+		{"Non default port", "http://example.com:8080/page", "http://example.com:8080/page"},
+		{"Lowercase host", "HTTP://WWW.Example.COM/Page", "http://www.example.com/Page"},
+		{"Empty path", "http://example.com", "http://example.com/"},
+		{"Ignored params", "http://example.com/?utm_source=x&id=1&fbclid=y&utm_medium=z", "http://example.com/?id=1"},
+		{"Escaping", "http://example.com/?q=a%20b&q=a+c", "http://example.com/?q=a+b&q=a+c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, CanonicalizeURL(u, DefaultIgnoredParams, false).String())
+		})
+	}
+}
+
+func TestDefaultFingerprinter(t *testing.T) {
+	ctx := context.Background()
+	fingerprint := func(f Fingerprinter, method, url, body string, headers ...string) string {
+		req, err := NewRequest(ctx, method, url, strings.NewReader(body))
+		assert.NoError(t, err)
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		return f.Fingerprint(req.Request)
+	}
+
+	f := &DefaultFingerprinter{}
+	assert.Equal(t, fingerprint(f, "GET", "http://example.com/?a=1&b=2", ""), fingerprint(f, "GET", "http://example.com/?b=2&a=1#top", ""))
+	assert.NotEqual(t, fingerprint(f, "GET", "http://example.com/", ""), fingerprint(f, "POST", "http://example.com/", ""))
+	assert.Equal(t, fingerprint(f, "POST", "http://example.com/", "a=1"), fingerprint(f, "POST", "http://example.com/", "a=1"))
+	assert.NotEqual(t, fingerprint(f, "POST", "http://example.com/", "a=1"), fingerprint(f, "POST", "http://example.com/", "a=2"))
+	assert.Equal(t, fingerprint(f, "GET", "http://example.com/", "", "Accept-Language", "en"), fingerprint(f, "GET", "http://example.com/", "", "Accept-Language", "tr"))
+
+	// Bodies without GetBody aren't read, so request isn't modified
+	req, _ := http.NewRequest("POST", "http://example.com/", io.NopCloser(strings.NewReader("a=1")))
+	body := req.Body
+	assert.Equal(t, fingerprint(f, "POST", "http://example.com/", ""), f.Fingerprint(req))
+	assert.Equal(t, body, req.Body)
+
+	f = &DefaultFingerprinter{Headers: []string{"Accept-Language"}}
+	assert.NotEqual(t, fingerprint(f, "GET", "http://example.com/", "", "Accept-Language", "en"), fingerprint(f, "GET", "http://example.com/", "", "Accept-Language", "tr"))
+}
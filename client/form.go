@@ -0,0 +1,237 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+var (
+	// ErrFormNotFound is returned by NewFormRequestFromResponse if response has no matching form
+	ErrFormNotFound = errors.New("form not found")
+
+	// ErrClickableNotFound is returned by NewFormRequestFromResponse if form has no submit button matching FormClick
+	ErrClickableNotFound = errors.New("clickable not found")
+)
+
+// FormOption changes how NewFormRequestFromResponse submits a form
+type FormOption func(*formOptions)
+
+type formOptions struct {
+	clickSelector string
+	dontClick     bool
+}
+
+// FormClick submits the form by clicking the submit button matching selector in form, instead of the first one.
+// Only the clicked button's name and value are submitted.
+func FormClick(selector string) FormOption {
+	return func(o *formOptions) {
+		o.clickSelector = selector
+	}
+}
+
+// FormDontClick submits the form without clicking any submit button, so no button value is submitted
+func FormDontClick() FormOption {
+	return func(o *formOptions) {
+		o.dontClick = true
+	}
+}
+
+// NewFormRequestFromResponse returns a request submitting a form of HTML response.
+// formSelector selects the form, or an element inside of it. Empty selector selects the first form.
+//
+// Default values of form fields are collected like browsers do: inputs, checked checkboxes and radio buttons,
+// selected options, textareas and the clicked submit button. Disabled fields are skipped.
+// The first named submit button is clicked, unless FormClick or FormDontClick is given.
+// Values of overrides replace them, or are added if form has no such field.
+//
+// Request method, URL and encoding (application/x-www-form-urlencoded or multipart/form-data) are taken from form.
+// Request is created with context of response, so that it's one level deeper than response.
+func NewFormRequestFromResponse(res *Response, formSelector string, overrides url.Values, opts ...FormOption) (*Request, error) {
+	var o formOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if res.HTMLDoc == nil {
+		return nil, ErrFormNotFound
+	}
+	if formSelector == "" {
+		formSelector = "form"
+	}
+	form := res.HTMLDoc.Find(formSelector).First()
+	if !form.Is("form") {
+		form = form.Closest("form")
+	}
+	if form.Length() == 0 {
+		return nil, ErrFormNotFound
+	}
+
+	clicked, err := clickedButton(form, o)
+	if err != nil {
+		return nil, err
+	}
+	values := formValues(form, clicked)
+	for name, override := range overrides {
+		values[name] = override
+	}
+
+	action, err := res.BaseURL().Parse(strings.TrimSpace(form.AttrOr("action", "")))
+	if err != nil {
+		return nil, err
+	}
+	action.Fragment = ""
+
+	ctx := ContextWithResponse(res.Request.Context(), res)
+	var req *Request
+	if strings.EqualFold(form.AttrOr("method", "GET"), "POST") {
+		var body []byte
+		contentType := "application/x-www-form-urlencoded"
+		if strings.EqualFold(form.AttrOr("enctype", ""), "multipart/form-data") {
+			body, contentType, err = multipartBody(values)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			body = []byte(values.Encode())
+		}
+		if req, err = NewRequest(ctx, "POST", action.String(), bytes.NewReader(body)); err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", contentType)
+	} else {
+		action.RawQuery = values.Encode()
+		if req, err = NewRequest(ctx, "GET", action.String(), nil); err != nil {
+			return nil, err
+		}
+	}
+	req.Header.Set("Referer", Referer(res.Request.URL))
+	return req, nil
+}
+
+// isSubmit reports whether field is a submit button
+func isSubmit(field *goquery.Selection) bool {
+	switch goquery.NodeName(field) {
+	case "button":
+		return strings.EqualFold(field.AttrOr("type", "submit"), "submit")
+	case "input":
+		fieldType := strings.ToLower(field.AttrOr("type", ""))
+		return fieldType == "submit" || fieldType == "image"
+	}
+	return false
+}
+
+// clickedButton returns the submit button of form to be clicked, nil if none is clicked
+func clickedButton(form *goquery.Selection, o formOptions) (*html.Node, error) {
+	if o.dontClick {
+		return nil, nil
+	}
+	buttons := form.Find("input, button").FilterFunction(func(_ int, field *goquery.Selection) bool {
+		_, disabled := field.Attr("disabled")
+		return isSubmit(field) && !disabled
+	})
+	if o.clickSelector == "" {
+		// Unnamed buttons add nothing to form data. Image buttons are only clicked with FormClick.
+		named := buttons.FilterFunction(func(_ int, field *goquery.Selection) bool {
+			return field.AttrOr("name", "") != "" && !strings.EqualFold(field.AttrOr("type", ""), "image")
+		})
+		if named.Length() == 0 {
+			return nil, nil
+		}
+		return named.Get(0), nil
+	}
+	clicked := buttons.Filter(o.clickSelector)
+	if clicked.Length() == 0 {
+		return nil, ErrClickableNotFound
+	}
+	return clicked.Get(0), nil
+}
+
+// formValues returns default values of form fields, with the name and value of clicked submit button
+func formValues(form *goquery.Selection, clicked *html.Node) url.Values {
+	values := url.Values{}
+	form.Find("input, select, textarea, button").Each(func(_ int, field *goquery.Selection) {
+		name, ok := field.Attr("name")
+		if !ok || name == "" {
+			return
+		}
+		if _, disabled := field.Attr("disabled"); disabled {
+			return
+		}
+
+		switch goquery.NodeName(field) {
+		case "textarea":
+			values.Add(name, field.Text())
+		case "select":
+			_, multiple := field.Attr("multiple")
+			options := field.Find("option[selected]")
+			if options.Length() == 0 {
+				if multiple {
+					return
+				}
+				options = field.Find("option").First()
+			} else if !multiple {
+				// Browsers select only the last selected option of single selects
+				options = options.Last()
+			}
+			options.Each(func(_ int, option *goquery.Selection) {
+				values.Add(name, option.AttrOr("value", strings.TrimSpace(option.Text())))
+			})
+		case "button":
+			if field.Get(0) == clicked {
+				values.Add(name, field.AttrOr("value", ""))
+			}
+		default:
+			switch strings.ToLower(field.AttrOr("type", "text")) {
+			case "checkbox", "radio":
+				if _, checked := field.Attr("checked"); checked {
+					values.Add(name, field.AttrOr("value", "on"))
+				}
+			case "submit":
+				if field.Get(0) == clicked {
+					values.Add(name, field.AttrOr("value", ""))
+				}
+			case "image":
+				// Clicked image buttons submit click coordinates
+				if field.Get(0) == clicked {
+					values.Add(name+".x", "0")
+					values.Add(name+".y", "0")
+				}
+			case "reset", "button", "file":
+				// Not submitted without user interaction
+			default:
+				values.Add(name, field.AttrOr("value", ""))
+			}
+		}
+	})
+	return values
+}
+
+// multipartBody encodes values as multipart/form-data in order of their names
+func multipartBody(values url.Values) ([]byte, string, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, name := range names {
+		for _, value := range values[name] {
+			if err := w.WriteField(name, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
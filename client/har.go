@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// harSkippedHeaders are headers of HAR requests that are set by the HTTP client
+var harSkippedHeaders = map[string]bool{
+	"accept-encoding":   true,
+	"connection":        true,
+	"content-length":    true,
+	"host":              true,
+	"transfer-encoding": true,
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harFile is the part of HTTP Archive format needed to recreate requests
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method   string         `json:"method"`
+				URL      string         `json:"url"`
+				Headers  []harNameValue `json:"headers"`
+				Cookies  []harNameValue `json:"cookies"`
+				PostData *struct {
+					MimeType string         `json:"mimeType"`
+					Text     string         `json:"text"`
+					Params   []harNameValue `json:"params"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// LoadHAR returns requests of HTTP Archive (HAR) entries in order, such as the ones exported by browsers.
+// HTTP/2 pseudo headers and headers set by the HTTP client, like Host and Accept-Encoding, are skipped.
+// Bodies given as params are encoded as multipart/form-data if it's their mimeType, and urlencoded otherwise.
+func LoadHAR(ctx context.Context, r io.Reader) ([]*Request, error) {
+	var har harFile
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, fmt.Errorf("har: %w", err)
+	}
+
+	requests := make([]*Request, 0, len(har.Log.Entries))
+	for _, entry := range har.Log.Entries {
+		harReq := entry.Request
+
+		var body io.Reader
+		var contentType string
+		if postData := harReq.PostData; postData != nil {
+			contentType = postData.MimeType
+			text := postData.Text
+			if text == "" && len(postData.Params) != 0 {
+				params := url.Values{}
+				for _, param := range postData.Params {
+					params.Add(param.Name, param.Value)
+				}
+				if mediaType, _, _ := mime.ParseMediaType(postData.MimeType); mediaType == "multipart/form-data" {
+					// Params are encoded with a new boundary, so boundary of the archived request isn't used
+					data, multipartType, err := multipartBody(params)
+					if err != nil {
+						return nil, fmt.Errorf("har: %w", err)
+					}
+					text, contentType = string(data), multipartType
+				} else {
+					text = params.Encode()
+				}
+			}
+			body = strings.NewReader(text)
+		}
+
+		req, err := NewRequest(ctx, harReq.Method, harReq.URL, body)
+		if err != nil {
+			return nil, fmt.Errorf("har: %w", err)
+		}
+		for _, h := range harReq.Headers {
+			if strings.HasPrefix(h.Name, ":") || harSkippedHeaders[strings.ToLower(h.Name)] {
+				continue
+			}
+			req.Header.Add(h.Name, h.Value)
+		}
+		if req.Header.Get("Cookie") == "" && len(harReq.Cookies) != 0 {
+			cookies := make([]string, 0, len(harReq.Cookies))
+			for _, cookie := range harReq.Cookies {
+				cookies = append(cookies, cookie.Name+"="+cookie.Value)
+			}
+			req.Header.Set("Cookie", strings.Join(cookies, "; "))
+		}
+		// Content-Type of re-encoded multipart bodies replaces the archived one, as its boundary is changed
+		if contentType != "" && (contentType != harReq.PostData.MimeType || req.Header.Get("Content-Type") == "") {
+			req.Header.Set("Content-Type", contentType)
+		}
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+// LoadHARFile returns requests of HAR file, see LoadHAR
+func LoadHARFile(ctx context.Context, path string) ([]*Request, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadHAR(ctx, f)
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const har = `{"log": {"version": "1.2", "entries": [
+  {"request": {"method": "GET", "url": "https://example.com/",
+    "headers": [{"name": ":authority", "value": "example.com"}, {"name": "accept", "value": "text/html"}, {"name": "accept-encoding", "value": "gzip"}],
+    "cookies": [{"name": "a", "value": "1"}, {"name": "b", "value": "2"}]}},
+  {"request": {"method": "POST", "url": "https://example.com/login",
+    "headers": [{"name": "Content-Length", "value": "11"}],
+    "postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "user", "value": "me"}]}}},
+  {"request": {"method": "POST", "url": "https://example.com/upload",
+    "headers": [{"name": "Content-Type", "value": "multipart/form-data; boundary=----archived"}],
+    "postData": {"mimeType": "multipart/form-data; boundary=----archived", "params": [{"name": "title", "value": "doc"}]}}}
+]}}`
+
+func TestLoadHAR(t *testing.T) {
+	requests, err := LoadHAR(context.Background(), strings.NewReader(har))
+	assert.NoError(t, err)
+	assert.Len(t, requests, 3)
+
+	assert.Equal(t, "https://example.com/", requests[0].URL.String())
+	assert.Equal(t, "text/html", requests[0].Header.Get("Accept"))
+	assert.Equal(t, "a=1; b=2", requests[0].Header.Get("Cookie"))
+	assert.Len(t, requests[0].Header, 2)
+
+	assert.Equal(t, "POST", requests[1].Method)
+	assert.Equal(t, "application/x-www-form-urlencoded", requests[1].Header.Get("Content-Type"))
+	assert.Empty(t, requests[1].Header.Get("Content-Length"))
+	body, _ := io.ReadAll(requests[1].Body)
+	assert.Equal(t, "user=me", string(body))
+
+	assert.NotContains(t, requests[2].Header.Get("Content-Type"), "----archived")
+	assert.NoError(t, requests[2].ParseMultipartForm(1<<20))
+	assert.Equal(t, "doc", requests[2].FormValue("title"))
+}
@@ -1,10 +1,14 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
+	"net/url"
 	"sync/atomic"
+	"time"
 
 	"github.com/chromedp/chromedp"
 )
@@ -25,16 +29,58 @@ type Request struct {
 	Rendered bool
 
 	// Optional response body encoding. Leave empty for automatic detection.
-	// If you're having issues with auto detection, set this.
+	// If you're having issues with auto detection, set this. It's only applied to textual content types.
 	Encoding string
 
 	// Set this true to cancel requests. Should be used on middlewares.
 	Cancelled bool
 
+	// Requests with higher priority are processed first by priority schedulers.
+	// Default: 0
+	Priority int
+
+	// Timeout of this request, including reading body. Default: Client.Timeout
+	// Client.Timeout is only applied until response headers for StreamBody and SaveTo requests.
+	Timeout time.Duration
+
+	// Maximum number of times to retry this request. Set -1 to disable retrying.
+	// Default: RetryTimes of retry policy
+	RetryTimes int
+
+	// Which HTTP response codes to retry for this request. Default: Options.RetryHTTPCodes
+	RetryHTTPCodes []int
+
+	// Response of this request is retried if it returns true. It's called after body is read.
+	// It can't be serialized, so it's omitted by Marshal.
+	// Default: Options.RetryPredicate
+	RetryPredicate func(*Response) bool
+
+	// If true, request isn't filtered by duplicate request filter.
+	// Geziyor sets this on retried requests.
+	DontFilter bool
+
+	// If true, response body isn't read into Response.Body, it's given to callback as Response.BodyStream.
+	// Reading more than MaxBodySize fails with ErrBodyTooLarge. Charset isn't decoded. Not supported for Rendered requests.
+	// Reading body isn't limited by Client.Timeout, only by Timeout of request if it's set.
+	StreamBody bool
+
+	// SaveTo is the file path response body is streamed to, instead of reading it into Response.Body.
+	// Body is written to a temporary file in the same directory and renamed when it's completed and not retried,
+	// so an existing file isn't replaced by a failed response. Bodies of RetryHTTPCodes responses aren't saved.
+	// Bodies larger than MaxBodySize fail with ErrBodyTooLarge. Not supported for Rendered requests.
+	// Saving body isn't limited by Client.Timeout, only by Timeout of request if it's set.
+	SaveTo string
+
+	// Progress is called while body is saved to SaveTo, with written and total bytes. Total is -1 if it's unknown.
+	// It can't be serialized, so it's omitted by Marshal.
+	Progress func(written, total int64)
+
 	// Chrome actions to be run if the request is Rendered
 	Actions []chromedp.Action
 
 	retryCounter int32
+	depth        int
+	originRobots RobotsDirectives
 }
 
 // Cancel request
@@ -50,6 +96,33 @@ func (r *Request) RetryCount() int {
 	return int(atomic.LoadInt32(&r.retryCounter))
 }
 
+// Depth returns how many requests away this request is from the start requests.
+// Requests created with contexts of callbacks are one level deeper than the response of callback.
+func (r *Request) Depth() int {
+	return r.depth
+}
+
+// OriginRobots returns robots directives of the response this request is created from.
+// Requests created with contexts of callbacks are originated from the response of callback.
+func (r *Request) OriginRobots() RobotsDirectives {
+	return r.originRobots
+}
+
+type originKey struct{}
+
+// origin is the information about a response, stored in contexts of its callbacks.
+// It's kept small as contexts of requests live until they're processed.
+type origin struct {
+	depth  int
+	robots RobotsDirectives
+}
+
+// ContextWithResponse returns a copy of ctx in which requests created are marked as originated from res.
+// Geziyor calls callbacks with this context.
+func ContextWithResponse(ctx context.Context, res *Response) context.Context {
+	return context.WithValue(ctx, originKey{}, origin{depth: res.Request.Depth(), robots: res.Robots})
+}
+
 // NewRequest returns a new Request given a method, URL, and optional body.
 func NewRequest(ctx context.Context, method, url string, body io.Reader) (*Request, error) {
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
@@ -61,6 +134,112 @@ func NewRequest(ctx context.Context, method, url string, body io.Reader) (*Reque
 		Request: req,
 		Meta:    make(map[string]interface{}),
 	}
+	if o, ok := ctx.Value(originKey{}).(origin); ok {
+		request.depth = o.depth + 1
+		request.originRobots = o.robots
+	}
 
 	return &request, nil
 }
+
+// requestData is the serializable form of Request
+type requestData struct {
+	Method         string                 `json:"method"`
+	URL            string                 `json:"url"`
+	Header         http.Header            `json:"header,omitempty"`
+	Body           []byte                 `json:"body,omitempty"`
+	Meta           map[string]interface{} `json:"meta,omitempty"`
+	Rendered       bool                   `json:"rendered,omitempty"`
+	Encoding       string                 `json:"encoding,omitempty"`
+	Priority       int                    `json:"priority,omitempty"`
+	Timeout        time.Duration          `json:"timeout,omitempty"`
+	RetryTimes     int                    `json:"retry_times,omitempty"`
+	RetryHTTPCodes []int                  `json:"retry_http_codes,omitempty"`
+	DontFilter     bool                   `json:"dont_filter,omitempty"`
+	RetryCount     int                    `json:"retry_count,omitempty"`
+	Depth          int                    `json:"depth,omitempty"`
+	OriginRobots   *RobotsDirectives      `json:"origin_robots,omitempty"`
+	StreamBody     bool                   `json:"stream_body,omitempty"`
+	SaveTo         string                 `json:"save_to,omitempty"`
+}
+
+// Marshal encodes request as JSON, so it can be stored and recreated later using UnmarshalRequest.
+// Meta values must be JSON serializable and they're decoded as their JSON counterparts. (Numbers as float64 etc.)
+// Chrome Actions, RetryPredicate and Progress can't be serialized and are omitted.
+func (r *Request) Marshal() ([]byte, error) {
+	data := requestData{
+		Method:         r.Method,
+		URL:            r.URL.String(),
+		Header:         r.Header,
+		Meta:           r.Meta,
+		Rendered:       r.Rendered,
+		Encoding:       r.Encoding,
+		Priority:       r.Priority,
+		Timeout:        r.Timeout,
+		RetryTimes:     r.RetryTimes,
+		RetryHTTPCodes: r.RetryHTTPCodes,
+		DontFilter:     r.DontFilter,
+		RetryCount:     r.RetryCount(),
+		Depth:          r.depth,
+		StreamBody:     r.StreamBody,
+		SaveTo:         r.SaveTo,
+	}
+	if r.originRobots != (RobotsDirectives{}) {
+		data.OriginRobots = &r.originRobots
+	}
+
+	var err error
+	if data.Body, err = requestBody(r.Request); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(data)
+}
+
+// UnmarshalRequest recreates request encoded by Request.Marshal
+func UnmarshalRequest(ctx context.Context, data []byte) (*Request, error) {
+	var reqData requestData
+	if err := json.Unmarshal(data, &reqData); err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if len(reqData.Body) != 0 {
+		body = bytes.NewReader(reqData.Body)
+	}
+	req, err := NewRequest(ctx, reqData.Method, reqData.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	if reqData.Header != nil {
+		req.Header = reqData.Header
+	}
+	if reqData.Meta != nil {
+		req.Meta = reqData.Meta
+	}
+	req.Rendered = reqData.Rendered
+	req.Encoding = reqData.Encoding
+	req.Priority = reqData.Priority
+	req.Timeout = reqData.Timeout
+	req.RetryTimes = reqData.RetryTimes
+	req.RetryHTTPCodes = reqData.RetryHTTPCodes
+	req.DontFilter = reqData.DontFilter
+	req.retryCounter = int32(reqData.RetryCount)
+	req.depth = reqData.Depth
+	req.StreamBody = reqData.StreamBody
+	req.SaveTo = reqData.SaveTo
+	if reqData.OriginRobots != nil {
+		req.originRobots = *reqData.OriginRobots
+	}
+
+	return req, nil
+}
+
+// Referer returns u without fragment and user info, as they must not be sent in Referer header
+func Referer(u *url.URL) string {
+	stripped := *u
+	stripped.Fragment = ""
+	stripped.RawFragment = ""
+	stripped.User = nil
+	return stripped.String()
+}
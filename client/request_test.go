@@ -2,6 +2,8 @@ package client
 
 import (
 	"context"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,3 +16,51 @@ func TestMeta(t *testing.T) {
 
 	assert.Equal(t, req.Meta["key"], "value")
 }
+
+func TestRequestMarshal(t *testing.T) {
+	ctx := context.Background()
+	req, err := NewRequest(ctx, "POST", "https://github.com/toqueteos/geziyor?q=1", strings.NewReader("body"))
+	assert.NoError(t, err)
+	req.Header.Set("X-Key", "value")
+	req.Meta["key"] = "value"
+	req.Priority = 5
+	req.RetryCountInc()
+
+	data, err := req.Marshal()
+	assert.NoError(t, err)
+
+	got, err := UnmarshalRequest(ctx, data)
+	assert.NoError(t, err)
+	assert.Equal(t, req.Method, got.Method)
+	assert.Equal(t, req.URL.String(), got.URL.String())
+	assert.Equal(t, "value", got.Header.Get("X-Key"))
+	assert.Equal(t, "value", got.Meta["key"])
+	assert.Equal(t, 5, got.Priority)
+	assert.Equal(t, 1, got.RetryCount())
+
+	body, err := io.ReadAll(got.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "body", string(body))
+
+	// Original body isn't consumed
+	body, err = io.ReadAll(req.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "body", string(body))
+}
+
+func TestRequestDepth(t *testing.T) {
+	ctx := context.Background()
+	req, err := NewRequest(ctx, "GET", "https://github.com/toqueteos/geziyor", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, req.Depth())
+
+	ctx = ContextWithResponse(ctx, &Response{Request: req})
+	child, err := NewRequest(ctx, "GET", "https://github.com/toqueteos/geziyor/issues", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, child.Depth())
+
+	ctx = ContextWithResponse(ctx, &Response{Request: child})
+	grandChild, err := NewRequest(ctx, "GET", "https://github.com/toqueteos/geziyor/pulls", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, grandChild.Depth())
+}
@@ -1,9 +1,12 @@
 package client
 
 import (
+	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -20,10 +23,64 @@ type Response struct {
 	HTMLDoc *goquery.Document
 
 	Request *Request
+
+	// Latency is the time from sending request until response headers are received.
+	// For rendered requests, it's the time of whole rendering.
+	Latency time.Duration
+
+	// Robots directives of the response. Set by middleware.MetaRobots
+	Robots RobotsDirectives
+
+	// Encoding is the name of the encoding Body is decoded from to UTF-8. Empty if Body isn't decoded.
+	Encoding string
+
+	// EncodingSource is where Encoding is found
+	EncodingSource EncodingSource
+
+	// BodyStream is the response body of requests with StreamBody, instead of Body.
+	// Geziyor closes it after callback returns.
+	BodyStream io.ReadCloser
+
+	// SavedTo is the file path body is saved to for requests with SaveTo, instead of Body.
+	// While retry predicate is called, it's the temporary file body is written to.
+	// Empty if body isn't saved, e.g. status code is retried.
+	SavedTo string
+
+	// SavedSize is the number of body bytes saved to SavedTo
+	SavedSize int64
+
+	// savedPart is true while SavedTo is the temporary file, before it's renamed to Request.SaveTo
+	savedPart bool
+}
+
+// discardBody closes BodyStream if response is streamed and removes temporary file of saved body
+func (r *Response) discardBody() {
+	if r.BodyStream != nil {
+		r.BodyStream.Close()
+	}
+	if r.savedPart {
+		os.Remove(r.SavedTo)
+		r.SavedTo = ""
+		r.savedPart = false
+	}
+}
+
+// commitSavedBody renames temporary file of saved body to Request.SaveTo
+func (r *Response) commitSavedBody() error {
+	if !r.savedPart {
+		return nil
+	}
+	if err := os.Rename(r.SavedTo, r.Request.SaveTo); err != nil {
+		r.discardBody()
+		return err
+	}
+	r.SavedTo = r.Request.SaveTo
+	r.savedPart = false
+	return nil
 }
 
 // JoinURL joins base response URL and provided relative URL.
-// DEPRECATED: Use response.Request.URL.Parse(relativeURL) instead.
+// DEPRECATED: Use response.BaseURL().Parse(relativeURL) or Geziyor.Follow instead.
 func (r *Response) JoinURL(relativeURL string) string {
 	parsedRelativeURL, err := url.Parse(relativeURL)
 	if err != nil {
@@ -44,3 +101,16 @@ func (r *Response) IsHTML() bool {
 	}
 	return false
 }
+
+// BaseURL returns the URL that relative URLs of response are resolved against.
+// It's the href of <base> element if HTML document has one, otherwise the request URL.
+func (r *Response) BaseURL() *url.URL {
+	if r.HTMLDoc != nil {
+		if href, ok := r.HTMLDoc.Find("base[href]").First().Attr("href"); ok {
+			if base, err := r.Request.URL.Parse(strings.TrimSpace(href)); err == nil {
+				return base
+			}
+		}
+	}
+	return r.Request.URL
+}
@@ -2,8 +2,10 @@ package client
 
 import (
 	"context"
+	"strings"
 	"testing"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,3 +17,12 @@ func TestResponse_JoinURL(t *testing.T) {
 	joinedURL := resp.JoinURL("/source")
 	assert.Equal(t, "https://localhost.com/source", joinedURL)
 }
+
+func TestResponse_BaseURL(t *testing.T) {
+	req, _ := NewRequest(context.Background(), "GET", "https://localhost.com/test/a.html", nil)
+	resp := Response{Request: req}
+	assert.Equal(t, "https://localhost.com/test/a.html", resp.BaseURL().String())
+
+	resp.HTMLDoc, _ = goquery.NewDocumentFromReader(strings.NewReader(`<html><head><base href="/static/"></head></html>`))
+	assert.Equal(t, "https://localhost.com/static/", resp.BaseURL().String())
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default values for BackoffRetryPolicy
+const (
+	DefaultRetryBaseDelay = 1 * time.Second
+	DefaultRetryMaxDelay  = 30 * time.Second
+)
+
+// RetryPolicy decides whether failed requests are retried and how long to wait before retrying.
+type RetryPolicy interface {
+	// Retry returns the delay before retrying req and whether it should be retried.
+	// It's called after each failed attempt, before req.RetryCount is incremented.
+	Retry(req *Request, err *Error) (time.Duration, bool)
+}
+
+// BackoffRetryPolicy retries failed requests with exponential backoff and jitter.
+// Delay of n'th retry is a random duration between half and full of BaseDelay * 2^n, capped by MaxDelay.
+// If a response has Retry-After header, it's used as delay instead. (Still capped by MaxDelay)
+// Requests cancelled or with too large bodies are never retried.
+type BackoffRetryPolicy struct {
+	// Maximum number of times to retry, in addition to the first attempt.
+	// Request.RetryTimes overrides this for a request.
+	RetryTimes int
+
+	// Delay of the first retry. Default: DefaultRetryBaseDelay
+	BaseDelay time.Duration
+
+	// Maximum delay between retries. Default: DefaultRetryMaxDelay
+	MaxDelay time.Duration
+}
+
+// Retry implements RetryPolicy
+func (p *BackoffRetryPolicy) Retry(req *Request, err *Error) (time.Duration, bool) {
+	retryTimes := p.RetryTimes
+	if req.RetryTimes != 0 {
+		retryTimes = req.RetryTimes
+	}
+	if req.RetryCount() >= retryTimes {
+		return 0, false
+	}
+	if err.Kind == KindCancelled || err.Kind == KindBodyTooLarge {
+		return 0, false
+	}
+
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+
+	if err.Response != nil && err.Response.Header != nil {
+		if delay, ok := ParseRetryAfter(err.Response.Header.Get("Retry-After"), time.Now()); ok {
+			if delay > maxDelay {
+				delay = maxDelay
+			}
+			return delay, true
+		}
+	}
+
+	delay := p.BaseDelay
+	if delay <= 0 {
+		delay = DefaultRetryBaseDelay
+	}
+	for i := 0; i < req.RetryCount() && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1)), true
+}
+
+// ParseRetryAfter parses value of Retry-After header given in seconds or as HTTP date.
+// Dates in the past are returned as zero delay.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"Mon, 01 Jan 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Mon, 01 Jan 2024 11:00:00 GMT", 0, true},
+		{"-5", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseRetryAfter(tt.value, now)
+		assert.Equal(t, tt.ok, ok, tt.value)
+		assert.Equal(t, tt.want, got, tt.value)
+	}
+}
+
+func TestBackoffRetryPolicy(t *testing.T) {
+	policy := &BackoffRetryPolicy{RetryTimes: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	req, _ := NewRequest(context.Background(), "GET", "https://example.com", nil)
+	timeoutErr := &Error{Kind: KindTimeout, Request: req}
+
+	// Exponential backoff with jitter, capped by MaxDelay
+	for _, maxDelay := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond} {
+		delay, retry := policy.Retry(req, timeoutErr)
+		assert.True(t, retry)
+		assert.GreaterOrEqual(t, delay, maxDelay/2)
+		assert.LessOrEqual(t, delay, maxDelay)
+		req.RetryCountInc()
+	}
+	_, retry := policy.Retry(req, timeoutErr)
+	assert.False(t, retry, "retry times exhausted")
+
+	// Per request retry times
+	req, _ = NewRequest(context.Background(), "GET", "https://example.com", nil)
+	req.RetryTimes = -1
+	_, retry = policy.Retry(req, timeoutErr)
+	assert.False(t, retry)
+
+	// Retry-After is used as delay
+	req, _ = NewRequest(context.Background(), "GET", "https://example.com", nil)
+	res := &Response{Response: &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"1"}}}}
+	delay, retry := policy.Retry(req, &Error{Kind: KindHTTPStatus, Request: req, Response: res})
+	assert.True(t, retry)
+	assert.Equal(t, 300*time.Millisecond, delay)
+
+	// Not retried
+	for _, kind := range []ErrorKind{KindCancelled, KindBodyTooLarge} {
+		_, retry = policy.Retry(req, &Error{Kind: kind, Request: req})
+		assert.False(t, retry, kind.String())
+	}
+}
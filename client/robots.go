@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"strings"
+)
+
+// RobotsDirectives are the indexing directives of a response,
+// given by <meta name="robots"> tags and X-Robots-Tag headers.
+type RobotsDirectives struct {
+	// Content of the response shouldn't be indexed
+	NoIndex bool `json:"noindex,omitempty"`
+	// Links of the response shouldn't be followed
+	NoFollow bool `json:"nofollow,omitempty"`
+}
+
+// robotsDirectivesWithValue are directives that contain colon, so they're not user agent names
+var robotsDirectivesWithValue = map[string]bool{
+	"unavailable_after": true,
+	"max-snippet":       true,
+	"max-image-preview": true,
+	"max-video-preview": true,
+}
+
+// Parse adds directives of an X-Robots-Tag header or a robots meta tag content to d.
+// Header values can be prefixed with user agent name, like "googlebot: noindex".
+// They're ignored unless the name matches userAgent, see MatchRobotsAgent.
+func (d *RobotsDirectives) Parse(value string, userAgent string) {
+	if name, rest, found := strings.Cut(value, ":"); found {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !robotsDirectivesWithValue[name] && !strings.Contains(name, ",") {
+			if !MatchRobotsAgent(name, userAgent) {
+				return
+			}
+			value = rest
+		}
+	}
+	for _, directive := range strings.Split(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "noindex":
+			d.NoIndex = true
+		case "nofollow":
+			d.NoFollow = true
+		case "none":
+			d.NoIndex = true
+			d.NoFollow = true
+		}
+	}
+}
+
+// MatchRobotsAgent reports whether robots directives for user agent name apply to userAgent.
+// name is compared case insensitively with the product token of userAgent, like "geziyor" of "Geziyor/1.0 (+https://geziyor.com)".
+func MatchRobotsAgent(name string, userAgent string) bool {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+	if i := strings.IndexAny(token, " \t;("); i >= 0 {
+		token = token[:i]
+	}
+	return token != "" && strings.EqualFold(strings.TrimSpace(name), token)
+}
+
+// RobotsFromContext returns robots directives of the response whose callback is called with ctx.
+// See ContextWithResponse
+func RobotsFromContext(ctx context.Context) RobotsDirectives {
+	o, _ := ctx.Value(originKey{}).(origin)
+	return o.robots
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRobotsDirectivesParse(t *testing.T) {
+	tests := []struct {
+		value string
+		want  RobotsDirectives
+	}{
+		{"noindex", RobotsDirectives{NoIndex: true}},
+		{"NoIndex, NOFOLLOW", RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{"none", RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{"index, follow, max-snippet:20", RobotsDirectives{}},
+		{"unavailable_after: 25 Jun 2010 15:00:00 PST", RobotsDirectives{}},
+		{"geziyor: nofollow", RobotsDirectives{NoFollow: true}},
+		{"googlebot: noindex", RobotsDirectives{}},
+		{"ge: noindex", RobotsDirectives{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			var d RobotsDirectives
+			d.Parse(tt.value, "Geziyor 1.0")
+			assert.Equal(t, tt.want, d)
+		})
+	}
+}
+
+func TestMatchRobotsAgent(t *testing.T) {
+	assert.True(t, MatchRobotsAgent("geziyor", "Geziyor/1.0 (+https://geziyor.com)"))
+	assert.True(t, MatchRobotsAgent("GEZIYOR", "geziyor"))
+	assert.True(t, MatchRobotsAgent("googlebot", "Googlebot"))
+	assert.False(t, MatchRobotsAgent("bot", "Geziyorbot/1.0"))
+	assert.False(t, MatchRobotsAgent("mozilla", "Geziyor/1.0 (compatible; Mozilla/5.0)"))
+	assert.False(t, MatchRobotsAgent("", ""))
+}
+
+func TestOriginRobots(t *testing.T) {
+	req, _ := NewRequest(context.Background(), "GET", "https://example.com", nil)
+	res := &Response{Request: req, Robots: RobotsDirectives{NoFollow: true}}
+	ctx := ContextWithResponse(context.Background(), res)
+	assert.Equal(t, res.Robots, RobotsFromContext(ctx))
+
+	next, _ := NewRequest(ctx, "GET", "https://example.com/next", nil)
+	assert.True(t, next.OriginRobots().NoFollow)
+
+	data, err := next.Marshal()
+	assert.NoError(t, err)
+	unmarshalled, err := UnmarshalRequest(context.Background(), data)
+	assert.NoError(t, err)
+	assert.Equal(t, next.OriginRobots(), unmarshalled.OriginRobots())
+}
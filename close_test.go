@@ -0,0 +1,86 @@
+package geziyor_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+)
+
+// newEndlessServer creates a server that every page links to the next one
+func newEndlessServer(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		page, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		fmt.Fprintf(w, "/%d", page+1)
+	}))
+}
+
+func TestCloseConditions(t *testing.T) {
+	ts := newEndlessServer(0)
+	defer ts.Close()
+	brokenURL := "http://localhost:0"
+
+	tests := []struct {
+		name    string
+		opt     geziyor.Options
+		want    string
+		maxSeen int
+	}{
+		{"ItemCount", geziyor.Options{CloseOnItemCount: 3}, geziyor.CloseItemCount, 4},
+		{"PageCount", geziyor.Options{CloseOnPageCount: 3}, geziyor.ClosePageCount, 3},
+		{"ErrorCount", geziyor.Options{CloseOnErrorCount: 1, StartURLs: []string{brokenURL}}, geziyor.CloseErrorCount, 0},
+		{"Timeout", geziyor.Options{CloseOnTimeout: 100 * time.Millisecond}, geziyor.CloseTimeout, -1},
+		{"Finished", geziyor.Options{StartURLs: []string{ts.URL + "/0"}, DepthLimit: 2}, geziyor.CloseFinished, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := 0
+			opt := tt.opt
+			if opt.StartURLs == nil {
+				opt.StartURLs = []string{ts.URL + "/0"}
+			}
+			opt.ParseFunc = func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+				seen++
+				g.Exports <- seen
+				g.Get(ctx, ts.URL+string(r.Body), g.Opt.ParseFunc)
+			}
+			opt.ConcurrentRequests = 1
+			opt.RetryTimes = -1
+			opt.RobotsTxtDisabled = true
+			opt.LogDisabled = true
+
+			ctx := context.Background()
+			s := geziyor.NewGeziyor(ctx, &opt).Start(ctx)
+			assert.Equal(t, tt.want, s.CloseReason)
+			if tt.maxSeen >= 0 {
+				assert.LessOrEqual(t, seen, tt.maxSeen)
+			}
+		})
+	}
+}
+
+func TestCloseCancelled(t *testing.T) {
+	ts := newEndlessServer(10 * time.Millisecond)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s := geziyor.NewGeziyor(ctx, &geziyor.Options{
+		StartURLs: []string{ts.URL + "/0"},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			g.Get(ctx, ts.URL+string(r.Body), g.Opt.ParseFunc)
+		},
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(ctx)
+	assert.Equal(t, geziyor.CloseCancelled, s.CloseReason)
+}
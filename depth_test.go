@@ -0,0 +1,40 @@
+package geziyor_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+)
+
+func TestDepthLimit(t *testing.T) {
+	// Every page links to the next one
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		fmt.Fprintf(w, "/%d", page+1)
+	}))
+	defer ts.Close()
+
+	var depths []int
+	ctx := context.Background()
+	geziyor.NewGeziyor(ctx, &geziyor.Options{
+		StartURLs: []string{ts.URL + "/0"},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			depths = append(depths, r.Request.Depth())
+			assert.Equal(t, "/"+strconv.Itoa(r.Request.Depth()), r.Request.URL.Path)
+			g.Get(ctx, ts.URL+string(r.Body), g.Opt.ParseFunc)
+		},
+		DepthLimit:        3,
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(ctx)
+
+	assert.Equal(t, []int{0, 1, 2, 3}, depths)
+}
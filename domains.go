@@ -0,0 +1,165 @@
+package geziyor
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/toqueteos/geziyor/client"
+	"golang.org/x/net/publicsuffix"
+	"golang.org/x/time/rate"
+)
+
+// DomainSettings overrides Options for requests to matching hosts.
+// Zero values fall back to Options.
+type DomainSettings struct {
+	// Maximum number of concurrent requests, shared by all hosts matching these settings.
+	// Default: Options.ConcurrentRequestsPerDomain per host
+	ConcurrentRequests int
+
+	// Headers added to requests unless they're already set
+	Headers http.Header
+
+	// Proxy of requests. Default: Options.ProxyFunc
+	ProxyFunc func(*http.Request) (*url.URL, error)
+
+	// Delay between requests. Options.RequestDelayRandomize applies to it. Default: Options.RequestDelay
+	RequestDelay time.Duration
+
+	// Requests per second, shared by all hosts matching these settings. Default: Options.RequestsPerSecond
+	RequestsPerSecond float64
+
+	// Timeout of requests. Default: Options.Timeout
+	Timeout time.Duration
+
+	// User agent of requests. Default: Options.UserAgent
+	UserAgent string
+}
+
+// domain is the limiters and settings of a DomainSettings key.
+// active is the number of requests being made to its hosts, guarded by scheduler lock.
+type domain struct {
+	*DomainSettings
+	rateLimiter *rate.Limiter
+	active      int
+}
+
+// domains finds settings of hosts. Keys are matched in order:
+//   - Exact host name: "www.example.com"
+//   - Longest suffix pattern: "*.example.com" matches subdomains of example.com
+//   - Registered domain: "example.com" matches any host under example.com
+type domains struct {
+	byKey map[string]*domain
+	mut   sync.RWMutex
+	hosts map[string]*domain
+}
+
+func newDomains(settings map[string]*DomainSettings) *domains {
+	d := &domains{
+		byKey: make(map[string]*domain, len(settings)),
+		hosts: make(map[string]*domain),
+	}
+	for key, s := range settings {
+		dom := &domain{DomainSettings: s}
+		if s.RequestsPerSecond != 0 {
+			dom.rateLimiter = newRateLimiter(s.RequestsPerSecond)
+		}
+		d.byKey[strings.ToLower(key)] = dom
+	}
+	return d
+}
+
+// newRateLimiter returns limiter of requests per second.
+// Burst is at least 1, so that rates below 1 request per second can be reserved.
+func newRateLimiter(rps float64) *rate.Limiter {
+	burst := int(math.Ceil(rps))
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(rps), burst)
+}
+
+// lookup returns the domain of host, nil if there isn't any
+func (d *domains) lookup(host string) *domain {
+	if len(d.byKey) == 0 {
+		return nil
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.ToLower(host)
+
+	d.mut.RLock()
+	dom, exists := d.hosts[host]
+	d.mut.RUnlock()
+	if exists {
+		return dom
+	}
+
+	dom = d.match(host)
+	d.mut.Lock()
+	d.hosts[host] = dom
+	d.mut.Unlock()
+	return dom
+}
+
+func (d *domains) match(host string) *domain {
+	if dom, exists := d.byKey[host]; exists {
+		return dom
+	}
+	for suffix := host; ; {
+		i := strings.IndexByte(suffix, '.')
+		if i < 0 {
+			break
+		}
+		suffix = suffix[i+1:]
+		if dom, exists := d.byKey["*."+suffix]; exists {
+			return dom
+		}
+	}
+	if registered, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return d.byKey[registered]
+	}
+	return nil
+}
+
+// proxyFunc returns proxy function that uses ProxyFunc of domain settings, or fallback
+func (d *domains) proxyFunc(fallback func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if dom := d.lookup(req.URL.Host); dom != nil && dom.ProxyFunc != nil {
+			return dom.ProxyFunc(req)
+		}
+		return fallback(req)
+	}
+}
+
+// domainSettings applies user agent, headers and timeout of domain settings to requests.
+// Limits and delays of domain settings are applied by host slots.
+type domainSettings struct {
+	domains *domains
+}
+
+func (m *domainSettings) ProcessRequest(r *client.Request) {
+	dom := m.domains.lookup(r.Host)
+	if dom == nil {
+		return
+	}
+
+	if dom.UserAgent != "" {
+		client.SetDefaultHeader(r.Header, "User-Agent", dom.UserAgent)
+	}
+	for key, values := range dom.Headers {
+		if r.Header.Get(key) == "" {
+			for _, value := range values {
+				r.Header.Add(key, value)
+			}
+		}
+	}
+	if r.Timeout == 0 {
+		r.Timeout = dom.Timeout
+	}
+}
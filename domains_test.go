@@ -0,0 +1,116 @@
+package geziyor_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+)
+
+func TestDomainSettings(t *testing.T) {
+	// Test server is used as proxy of all hosts
+	var mut sync.Mutex
+	userAgents := make(map[string]string)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		defer mut.Unlock()
+		userAgents[r.Host] = r.UserAgent() + " " + r.Header.Get("X-Partner")
+	}))
+	defer ts.Close()
+	proxyURL, _ := url.Parse(ts.URL)
+	proxy := http.ProxyURL(proxyURL)
+
+	geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartURLs: []string{
+			"http://www.example.com",
+			"http://shop.example.com",
+			"http://example.com",
+			"http://news.example.co.uk",
+			"http://example.org",
+		},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {},
+		DomainSettings: map[string]*geziyor.DomainSettings{
+			"www.example.com": {UserAgent: "exact"},
+			"*.example.com":   {UserAgent: "suffix", Headers: http.Header{"X-Partner": {"yes"}}},
+			"example.co.uk":   {UserAgent: "registered"},
+		},
+		UserAgent:         "default",
+		ProxyFunc:         proxy,
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(context.Background())
+
+	assert.Equal(t, map[string]string{
+		"www.example.com":    "exact ",
+		"shop.example.com":   "suffix yes",
+		"example.com":        "default ",
+		"news.example.co.uk": "registered ",
+		"example.org":        "default ",
+	}, userAgents)
+}
+
+func TestDomainSettingsLimits(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	var errs []error
+	start := time.Now()
+	geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartURLs: []string{ts.URL + "/1", ts.URL + "/2", ts.URL + "/3", ts.URL + "/slow"},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {},
+		ErrorFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Request, err error) {
+			errs = append(errs, err)
+		},
+		DomainSettings: map[string]*geziyor.DomainSettings{
+			u.Hostname(): {ConcurrentRequests: 1, RequestDelay: 50 * time.Millisecond, Timeout: 100 * time.Millisecond},
+		},
+		RetryTimes:        -1,
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(context.Background())
+
+	// Requests are made one by one with delays and slow request timed out
+	assert.GreaterOrEqual(t, time.Since(start), 4*50*time.Millisecond)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, client.KindTimeout, errs[0].(*client.Error).Kind)
+	}
+}
+
+func TestDomainSettingsSlowRate(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	var mut sync.Mutex
+	var times []time.Time
+	geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartURLs: []string{ts.URL + "/1", ts.URL + "/2"},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			mut.Lock()
+			defer mut.Unlock()
+			times = append(times, time.Now())
+		},
+		DomainSettings: map[string]*geziyor.DomainSettings{
+			u.Hostname(): {RequestsPerSecond: 0.5},
+		},
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(context.Background())
+
+	// Rates below 1 request per second are limited too
+	if assert.Len(t, times, 2) {
+		assert.GreaterOrEqual(t, times[1].Sub(times[0]), 1900*time.Millisecond)
+	}
+}
@@ -0,0 +1,140 @@
+package dupefilter
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"math"
+	"sync"
+)
+
+// Default values for Bloom filter
+const (
+	DefaultBloomCapacity          = 100000
+	DefaultBloomFalsePositiveRate = 0.001
+)
+
+const (
+	// bloomGrowth is the capacity multiplier of each new filter
+	bloomGrowth = 2
+	// bloomTightening is the false positive rate multiplier of each new filter
+	bloomTightening = 0.8
+)
+
+// Bloom is a scalable Bloom filter DupeFilter.
+// It uses a fixed amount of memory per fingerprint regardless of URL lengths,
+// at the cost of reporting some unseen fingerprints as seen with the configured false positive rate.
+// When a filter is full, a new one with double capacity and tighter error rate is added,
+// so the overall false positive rate stays under the configured rate.
+type Bloom struct {
+	mut               sync.Mutex
+	filters           []*bloomFilter
+	falsePositiveRate float64
+	count             int
+}
+
+// NewBloom returns a new scalable Bloom filter that initially allocates memory for capacity fingerprints.
+// Zero values use DefaultBloomCapacity and DefaultBloomFalsePositiveRate.
+func NewBloom(capacity int, falsePositiveRate float64) *Bloom {
+	if capacity <= 0 {
+		capacity = DefaultBloomCapacity
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = DefaultBloomFalsePositiveRate
+	}
+	b := &Bloom{falsePositiveRate: falsePositiveRate}
+	// Sum of the error rates of all filters converges to falsePositiveRate
+	b.filters = append(b.filters, newBloomFilter(capacity, falsePositiveRate*(1-bloomTightening)))
+	return b
+}
+
+// Visit marks fingerprint as seen and reports whether it was seen before
+func (b *Bloom) Visit(fingerprint string) bool {
+	h1, h2 := bloomHash(fingerprint)
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	for _, f := range b.filters {
+		if f.has(h1, h2) {
+			return true
+		}
+	}
+
+	last := b.filters[len(b.filters)-1]
+	if last.count >= last.capacity {
+		last = newBloomFilter(last.capacity*bloomGrowth, last.falsePositiveRate*bloomTightening)
+		b.filters = append(b.filters, last)
+	}
+	last.add(h1, h2)
+	b.count++
+	return false
+}
+
+// Seen reports whether fingerprint is seen, or is a false positive
+func (b *Bloom) Seen(fingerprint string) bool {
+	h1, h2 := bloomHash(fingerprint)
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	for _, f := range b.filters {
+		if f.has(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of stored fingerprints
+func (b *Bloom) Len() int {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.count
+}
+
+// bloomHash returns two independent hashes of s for double hashing
+func bloomHash(s string) (uint64, uint64) {
+	h := fnv.New128a()
+	_, _ = io.WriteString(h, s)
+	sum := h.Sum(nil)
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+// bloomFilter is a fixed size Bloom filter
+type bloomFilter struct {
+	bits              []uint64
+	m                 uint64
+	k                 uint64
+	capacity          int
+	count             int
+	falsePositiveRate float64
+}
+
+func newBloomFilter(capacity int, falsePositiveRate float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Ceil(float64(m) / float64(capacity) * math.Ln2))
+	return &bloomFilter{
+		bits:              make([]uint64, (m+63)/64),
+		m:                 m,
+		k:                 k,
+		capacity:          capacity,
+		falsePositiveRate: falsePositiveRate,
+	}
+}
+
+func (f *bloomFilter) add(h1, h2 uint64) {
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+	f.count++
+}
+
+func (f *bloomFilter) has(h1, h2 uint64) bool {
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+// Package dupefilter provides storage backends for fingerprints of already seen requests.
+package dupefilter
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DupeFilter stores fingerprints of seen requests.
+// Implementations must be safe for concurrent use.
+type DupeFilter interface {
+	// Visit marks fingerprint as seen and reports whether it was seen before
+	Visit(fingerprint string) (seen bool)
+
+	// Len returns the number of stored fingerprints
+	Len() int
+}
+
+// Checker is implemented by DupeFilters that can report whether a fingerprint is seen without marking it
+type Checker interface {
+	// Seen reports whether fingerprint is seen
+	Seen(fingerprint string) bool
+}
+
+// Memory is a DupeFilter that keeps fingerprints in an in-memory map.
+type Memory struct {
+	seen  sync.Map
+	count int64
+}
+
+// NewMemory returns a new in-memory DupeFilter
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Visit marks fingerprint as seen and reports whether it was seen before
+func (m *Memory) Visit(fingerprint string) bool {
+	if _, seen := m.seen.LoadOrStore(fingerprint, struct{}{}); seen {
+		return true
+	}
+	atomic.AddInt64(&m.count, 1)
+	return false
+}
+
+// Seen reports whether fingerprint is seen
+func (m *Memory) Seen(fingerprint string) bool {
+	_, seen := m.seen.Load(fingerprint)
+	return seen
+}
+
+// Len returns the number of stored fingerprints
+func (m *Memory) Len() int {
+	return int(atomic.LoadInt64(&m.count))
+}
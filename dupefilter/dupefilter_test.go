@@ -0,0 +1,57 @@
+package dupefilter
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testDupeFilter(t *testing.T, f DupeFilter) {
+	checker := f.(Checker)
+	assert.False(t, checker.Seen("a"))
+	assert.False(t, checker.Seen("a"))
+	assert.False(t, f.Visit("a"))
+	assert.True(t, checker.Seen("a"))
+	assert.False(t, f.Visit("b"))
+	assert.True(t, f.Visit("a"))
+	assert.True(t, f.Visit("b"))
+	assert.Equal(t, 2, f.Len())
+}
+
+func TestMemory(t *testing.T) {
+	testDupeFilter(t, NewMemory())
+}
+
+func TestLevelDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seen")
+	f, err := NewLevelDB(path)
+	assert.NoError(t, err)
+	testDupeFilter(t, f)
+	assert.NoError(t, f.Close())
+
+	// Fingerprints are persisted
+	f, err = NewLevelDB(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, 2, f.Len())
+	assert.True(t, f.Visit("a"))
+}
+
+func TestBloom(t *testing.T) {
+	testDupeFilter(t, NewBloom(0, 0))
+}
+
+func TestBloomFalsePositiveRate(t *testing.T) {
+	f := NewBloom(1000, 0.01)
+	falsePositives := 0
+	for i := 0; i < 20000; i++ {
+		if f.Visit("https://example.com/" + strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	assert.Equal(t, 20000-falsePositives, f.Len())
+	assert.Greater(t, len(f.filters), 1)
+	assert.Less(t, float64(falsePositives)/20000, 0.01)
+}
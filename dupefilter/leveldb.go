@@ -0,0 +1,71 @@
+package dupefilter
+
+import (
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// LevelDB is a DupeFilter that stores fingerprints in a leveldb database.
+// Fingerprints survive between runs when the same path is used.
+type LevelDB struct {
+	Db    *leveldb.DB
+	mut   sync.Mutex
+	count int
+}
+
+// NewLevelDB returns a new LevelDB DupeFilter that will store leveldb in path
+func NewLevelDB(path string) (*LevelDB, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewLevelDBWithDB(db), nil
+}
+
+// NewLevelDBWithDB returns a new LevelDB DupeFilter using the provided leveldb as underlying storage.
+func NewLevelDBWithDB(db *leveldb.DB) *LevelDB {
+	f := &LevelDB{Db: db}
+
+	// Count previously stored fingerprints
+	iter := db.NewIterator(nil, nil)
+	for iter.Next() {
+		f.count++
+	}
+	iter.Release()
+
+	return f
+}
+
+// Visit marks fingerprint as seen and reports whether it was seen before
+func (f *LevelDB) Visit(fingerprint string) bool {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	key := []byte(fingerprint)
+	if seen, err := f.Db.Has(key, nil); err == nil && seen {
+		return true
+	}
+	if err := f.Db.Put(key, nil, nil); err == nil {
+		f.count++
+	}
+	return false
+}
+
+// Seen reports whether fingerprint is seen
+func (f *LevelDB) Seen(fingerprint string) bool {
+	seen, err := f.Db.Has([]byte(fingerprint), nil)
+	return err == nil && seen
+}
+
+// Len returns the number of stored fingerprints
+func (f *LevelDB) Len() int {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.count
+}
+
+// Close closes the underlying database
+func (f *LevelDB) Close() error {
+	return f.Db.Close()
+}
@@ -0,0 +1,63 @@
+package geziyor_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+)
+
+func TestErrback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	var mut sync.Mutex
+	errbackKinds := make(map[string]client.ErrorKind)
+	errback := func(ctx context.Context, g *geziyor.Geziyor, r *client.Request, err error) {
+		mut.Lock()
+		defer mut.Unlock()
+		var reqErr *client.Error
+		if errors.As(err, &reqErr) {
+			errbackKinds[r.URL.Path] = reqErr.Kind
+		}
+	}
+	var errorFuncCalls int
+
+	geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartRequestsFunc: func(ctx context.Context, g *geziyor.Geziyor) {
+			g.Get(ctx, ts.URL+"/ok", nil, geziyor.WithErrback(errback))
+			g.Get(ctx, ts.URL+"/broken", nil, geziyor.WithErrback(errback))
+		},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			// Duplicate of a visited page is cancelled by middleware
+			g.Get(ctx, ts.URL+"/ok", nil, geziyor.WithErrback(errback))
+			// Failure without errback goes to ErrorFunc
+			g.Get(ctx, ts.URL+"/broken?other", nil)
+		},
+		ErrorFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Request, err error) {
+			mut.Lock()
+			defer mut.Unlock()
+			errorFuncCalls++
+		},
+		ConcurrentRequests: 1,
+		RetryTimes:         -1,
+		RobotsTxtDisabled:  true,
+		LogDisabled:        true,
+	}).Start(context.Background())
+
+	assert.Equal(t, map[string]client.ErrorKind{
+		"/ok":     client.KindCancelled,
+		"/broken": client.KindHTTPStatus,
+	}, errbackKinds)
+	assert.Equal(t, 1, errorFuncCalls)
+}
@@ -2,7 +2,7 @@ package export
 
 import (
 	"io/ioutil"
-	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -11,10 +11,9 @@ import (
 
 func TestCSVExporter_Export(t *testing.T) {
 	exporter := &CSV{
-		FileName: "out.csv",
+		FileName: filepath.Join(t.TempDir(), "out.csv"),
 		Comma:    ';',
 	}
-	_ = os.Remove(exporter.FileName)
 	exports := make(chan interface{})
 	go exporter.Export(exports)
 
@@ -2,7 +2,7 @@ package export
 
 import (
 	"io/ioutil"
-	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -11,10 +11,9 @@ import (
 
 func TestJSONLineExporter_Export(t *testing.T) {
 	exporter := &JSONLine{
-		FileName: "out.json",
+		FileName: filepath.Join(t.TempDir(), "out.json"),
 		Indent:   " ",
 	}
-	_ = os.Remove(exporter.FileName)
 	exports := make(chan interface{})
 	go exporter.Export(exports)
 
@@ -29,9 +28,8 @@ func TestJSONLineExporter_Export(t *testing.T) {
 
 func TestJSONExporter_Export(t *testing.T) {
 	exporter := &JSON{
-		FileName: "out.json",
+		FileName: filepath.Join(t.TempDir(), "out.json"),
 	}
-	_ = os.Remove(exporter.FileName)
 	exports := make(chan interface{})
 	go exporter.Export(exports)
 
@@ -0,0 +1,87 @@
+package geziyor
+
+import (
+	"context"
+
+	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/dupefilter"
+	"github.com/toqueteos/geziyor/feed"
+	"github.com/toqueteos/geziyor/internal"
+)
+
+// feedCallback is the JobDir name of parseFeed
+const feedCallback = "geziyor:feed"
+
+// feedEntryCallback is the JobDir name of parseFeedEntry
+const feedEntryCallback = "geziyor:feedentry"
+
+// feedGUIDMeta is the Request.Meta key of GUID of feed entry requests, to mark them seen when they succeed
+const feedGUIDMeta = "feed_guid"
+
+// FeedEntryMeta is the Request.Meta key of feed.Entry of requests created from feed entries.
+// Requests restored from JobDir have the entry decoded as map[string]interface{}.
+const FeedEntryMeta = "feed_entry"
+
+// startFeeds requests Options.FeedURLs. Feeds are requested on every run, even if they're visited before.
+func (g *Geziyor) startFeeds(ctx context.Context) {
+	for _, feedURL := range g.Opt.FeedURLs {
+		g.Get(ctx, feedURL, parseFeed, func(req *ScheduledRequest) {
+			req.DontFilter = true
+		})
+	}
+}
+
+// parseFeed is the callback of feeds. New entries are requested with Options.FeedParseFunc.
+// Entries are marked as seen when their responses are received, so failed entries are requested again on next run.
+// If Options.FeedSeenFilter isn't a dupefilter.Checker, they're marked as seen when they're scheduled.
+func parseFeed(ctx context.Context, g *Geziyor, r *client.Response) {
+	checker, canCheck := g.feedSeen.(dupefilter.Checker)
+	f, err := feed.Parse(r.Body)
+	if err != nil {
+		internal.Logger.Printf("Feed parsing error %s: %v\n", r.Request.URL.String(), err)
+		return
+	}
+
+	for _, entry := range f.Entries {
+		if entry.Link == "" {
+			continue
+		}
+		if canCheck && checker.Seen(entry.GUID) || !canCheck && g.feedSeen.Visit(entry.GUID) {
+			continue
+		}
+		link, err := r.Request.URL.Parse(entry.Link)
+		if err != nil {
+			internal.Logger.Printf("Feed entry link error %s: %v\n", entry.Link, err)
+			continue
+		}
+		entry.Link = link.String()
+		req, err := client.NewRequest(ctx, "GET", entry.Link, nil)
+		if err != nil {
+			internal.Logger.Printf("Request creating error %v\n", err)
+			continue
+		}
+		req.Meta[FeedEntryMeta] = entry
+		if !canCheck {
+			g.Do(req, g.Opt.FeedParseFunc)
+			continue
+		}
+		// Seen entries are filtered by their GUIDs instead, so failed entries can be requested again
+		req.DontFilter = true
+		req.Meta[feedGUIDMeta] = entry.GUID
+		g.Do(req, parseFeedEntry)
+	}
+}
+
+// parseFeedEntry marks feed entry of response as seen and calls Options.FeedParseFunc
+func parseFeedEntry(ctx context.Context, g *Geziyor, r *client.Response) {
+	if guid, ok := r.Request.Meta[feedGUIDMeta].(string); ok {
+		g.feedSeen.Visit(guid)
+	}
+	callback := g.Opt.FeedParseFunc
+	if callback == nil {
+		callback = g.Opt.ParseFunc
+	}
+	if callback != nil {
+		callback(ctx, g, r)
+	}
+}
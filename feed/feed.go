@@ -0,0 +1,156 @@
+// Package feed parses RSS 2.0, Atom and RDF (RSS 1.0) feeds.
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Type is the format of feed
+type Type int
+
+const (
+	RSS Type = iota
+	Atom
+	RDF
+)
+
+// Feed is a parsed feed
+type Feed struct {
+	Type    Type
+	Title   string
+	Entries []Entry
+}
+
+// Entry is an item of RSS and RDF feeds, or an entry of Atom feeds
+type Entry struct {
+	Title string `json:"title"`
+	Link  string `json:"link"`
+	// GUID identifies entry. If feed doesn't give one, Link is used.
+	GUID string `json:"guid"`
+	// Published is zero if it's not given or can't be parsed
+	Published time.Time `json:"published"`
+}
+
+type xmlLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+// xmlEntry is the XML form of RSS items, RDF items and Atom entries
+type xmlEntry struct {
+	Title     string    `xml:"title"`
+	Links     []xmlLink `xml:"link"`
+	GUID      string    `xml:"guid"`
+	ID        string    `xml:"id"`
+	About     string    `xml:"about,attr"`
+	PubDate   string    `xml:"pubDate"`
+	Date      string    `xml:"date"`
+	Published string    `xml:"published"`
+	Updated   string    `xml:"updated"`
+}
+
+type xmlFeed struct {
+	XMLName xml.Name
+	Title   string `xml:"title"`
+	Channel struct {
+		Title string     `xml:"title"`
+		Items []xmlEntry `xml:"item"`
+	} `xml:"channel"`
+	Items   []xmlEntry `xml:"item"`
+	Entries []xmlEntry `xml:"entry"`
+}
+
+// dateLayouts are RFC 822 dates of RSS and RFC 3339 dates of Atom and Dublin Core
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+}
+
+// Parse parses RSS, Atom or RDF feed. data must be UTF-8, as response bodies are decoded to UTF-8 by client.
+// Encodings declared by XML declarations are ignored.
+func Parse(data []byte) (*Feed, error) {
+	var f xmlFeed
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := decoder.Decode(&f); err != nil {
+		return nil, fmt.Errorf("feed: %w", err)
+	}
+
+	switch f.XMLName.Local {
+	case "rss":
+		return &Feed{Type: RSS, Title: strings.TrimSpace(f.Channel.Title), Entries: convertEntries(f.Channel.Items)}, nil
+	case "feed":
+		return &Feed{Type: Atom, Title: strings.TrimSpace(f.Title), Entries: convertEntries(f.Entries)}, nil
+	case "RDF":
+		return &Feed{Type: RDF, Title: strings.TrimSpace(f.Channel.Title), Entries: convertEntries(f.Items)}, nil
+	default:
+		return nil, fmt.Errorf("feed: unknown root element %q", f.XMLName.Local)
+	}
+}
+
+func convertEntries(xmlEntries []xmlEntry) []Entry {
+	entries := make([]Entry, 0, len(xmlEntries))
+	for _, e := range xmlEntries {
+		entry := Entry{
+			Title:     strings.TrimSpace(e.Title),
+			Link:      entryLink(e.Links),
+			GUID:      firstNonEmpty(e.GUID, e.ID, e.About),
+			Published: parseDate(firstNonEmpty(e.PubDate, e.Published, e.Date, e.Updated)),
+		}
+		if entry.GUID == "" {
+			entry.GUID = entry.Link
+		}
+		if entry.GUID == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// entryLink returns link text of RSS, or alternate link of Atom
+func entryLink(links []xmlLink) string {
+	for _, link := range links {
+		if text := strings.TrimSpace(link.Text); text != "" {
+			return text
+		}
+		if link.Href != "" && (link.Rel == "" || link.Rel == "alternate") {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func parseDate(value string) time.Time {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
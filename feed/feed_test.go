@@ -0,0 +1,65 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	published := time.Date(2023, 4, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		data string
+		typ  Type
+	}{
+		{"RSS", `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel>
+  <title>News</title>
+  <atom:link href="https://example.com/rss" rel="self"/>
+  <item>
+    <title>First</title>
+    <link>https://example.com/1</link>
+    <guid isPermaLink="false">news-1</guid>
+    <pubDate>Sat, 01 Apr 2023 10:00:00 +0000</pubDate>
+  </item>
+</channel></rss>`, RSS},
+		{"Atom", `<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>News</title>
+  <entry>
+    <title type="html">First</title>
+    <link rel="alternate" href="https://example.com/1"/>
+    <id>news-1</id>
+    <published>2023-04-01T10:00:00Z</published>
+  </entry>
+</feed>`, Atom},
+		{"RDF", `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel rdf:about="https://example.com/"><title>News</title></channel>
+  <item rdf:about="news-1">
+    <title>First</title>
+    <link>https://example.com/1</link>
+    <dc:date>2023-04-01T10:00:00Z</dc:date>
+  </item>
+</rdf:RDF>`, RDF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse([]byte(tt.data))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.typ, f.Type)
+			assert.Equal(t, "News", f.Title)
+			assert.Len(t, f.Entries, 1)
+			assert.Equal(t, "First", f.Entries[0].Title)
+			assert.Equal(t, "https://example.com/1", f.Entries[0].Link)
+			assert.Equal(t, "news-1", f.Entries[0].GUID)
+			assert.True(t, published.Equal(f.Entries[0].Published))
+		})
+	}
+}
+
+func TestParseGUIDFallback(t *testing.T) {
+	f, err := Parse([]byte(`<rss><channel><item><link>https://example.com/1</link></item><item><title>No link</title></item></channel></rss>`))
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{{Link: "https://example.com/1", GUID: "https://example.com/1"}}, f.Entries)
+}
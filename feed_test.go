@@ -0,0 +1,68 @@
+package geziyor_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/feed"
+)
+
+func TestFeedURLs(t *testing.T) {
+	entries := 1
+	failing := "/news/1"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/rss" {
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, "<rss><channel>")
+		for i := entries; i > 0; i-- {
+			fmt.Fprintf(w, "<item><title>Entry %d</title><link>/news/%d</link><guid>news-%d</guid></item>", i, i, i)
+		}
+		fmt.Fprint(w, "</channel></rss>")
+	}))
+	defer ts.Close()
+
+	jobDir := t.TempDir()
+	run := func() []feed.Entry {
+		var mut sync.Mutex
+		var fetched []feed.Entry
+		geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+			FeedURLs: []string{ts.URL + "/rss"},
+			FeedParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+				mut.Lock()
+				defer mut.Unlock()
+				fetched = append(fetched, r.Request.Meta[geziyor.FeedEntryMeta].(feed.Entry))
+			},
+			JobDir:            jobDir,
+			RetryTimes:        -1,
+			RobotsTxtDisabled: true,
+			LogDisabled:       true,
+		}).Start(context.Background())
+		return fetched
+	}
+
+	// Failed entries aren't marked as seen
+	assert.Empty(t, run())
+	failing = ""
+	assert.Equal(t, []feed.Entry{{Title: "Entry 1", Link: ts.URL + "/news/1", GUID: "news-1"}}, run())
+
+	// Only new entries are fetched on next run
+	entries = 3
+	fetched := run()
+	assert.Len(t, fetched, 2)
+	for _, entry := range fetched {
+		assert.NotEqual(t, "news-1", entry.GUID)
+	}
+}
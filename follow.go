@@ -0,0 +1,97 @@
+package geziyor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/internal"
+	"github.com/toqueteos/geziyor/linkextractor"
+)
+
+// Follow makes a GET request to a link of response. target is one of:
+//   - string, an absolute or relative URL
+//   - *goquery.Selection, whose first element's href or src attribute is used
+//   - linkextractor.Link
+//
+// Relative URLs are resolved against Response.BaseURL. The request has Referer header of response URL,
+// Rendered and Encoding of response's request, and Meta keys listed in Options.FollowMetaKeys.
+// ctx should be the context of callback, so that depth of request is tracked.
+func (g *Geziyor) Follow(ctx context.Context, res *client.Response, target interface{}, callback ParseFunc, opts ...RequestOption) {
+	href, err := followHref(target)
+	if err != nil {
+		internal.Logger.Printf("Follow error: %v\n", err)
+		return
+	}
+	g.follow(ctx, res, href, callback, opts...)
+}
+
+// FollowAll follows every link of targets, see Follow. targets is one of:
+//   - []string
+//   - *goquery.Selection, every element is followed
+//   - []linkextractor.Link
+func (g *Geziyor) FollowAll(ctx context.Context, res *client.Response, targets interface{}, callback ParseFunc, opts ...RequestOption) {
+	switch t := targets.(type) {
+	case []string:
+		for _, href := range t {
+			g.follow(ctx, res, href, callback, opts...)
+		}
+	case *goquery.Selection:
+		t.Each(func(_ int, s *goquery.Selection) {
+			g.Follow(ctx, res, s, callback, opts...)
+		})
+	case []linkextractor.Link:
+		for _, link := range t {
+			g.follow(ctx, res, link.URL, callback, opts...)
+		}
+	default:
+		internal.Logger.Printf("FollowAll error: unsupported targets type %T\n", targets)
+	}
+}
+
+// followHref returns URL of Follow target
+func followHref(target interface{}) (string, error) {
+	switch t := target.(type) {
+	case string:
+		return t, nil
+	case linkextractor.Link:
+		return t.URL, nil
+	case *goquery.Selection:
+		if href, ok := t.First().Attr("href"); ok {
+			return href, nil
+		}
+		if src, ok := t.First().Attr("src"); ok {
+			return src, nil
+		}
+		return "", fmt.Errorf("selection has no href or src attribute")
+	default:
+		return "", fmt.Errorf("unsupported target type %T", target)
+	}
+}
+
+func (g *Geziyor) follow(ctx context.Context, res *client.Response, href string, callback ParseFunc, opts ...RequestOption) {
+	u, err := res.BaseURL().Parse(strings.TrimSpace(href))
+	if err != nil {
+		internal.Logger.Printf("Follow error: %v\n", err)
+		return
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	req, err := client.NewRequest(ctx, "GET", u.String(), nil)
+	if err != nil {
+		internal.Logger.Printf("Request creating error %v\n", err)
+		return
+	}
+	req.Header.Set("Referer", client.Referer(res.Request.URL))
+	req.Rendered = res.Request.Rendered
+	req.Encoding = res.Request.Encoding
+	for _, key := range g.Opt.FollowMetaKeys {
+		if value, exists := res.Request.Meta[key]; exists {
+			req.Meta[key] = value
+		}
+	}
+	g.Do(req, callback, opts...)
+}
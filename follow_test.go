@@ -0,0 +1,62 @@
+package geziyor_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+)
+
+func TestFollow(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/list/" {
+			fmt.Fprint(w, `<html><head><base href="/items/"></head><body>
+<a class="item" href="1#reviews">1</a><a class="item" href="2">2</a><a class="next" href="/list/?page=2">Next</a>
+</body></html>`)
+		}
+	}))
+	defer ts.Close()
+
+	type visit struct {
+		path, referer string
+		meta          map[string]interface{}
+	}
+	var mut sync.Mutex
+	var visits []visit
+	record := func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+		mut.Lock()
+		defer mut.Unlock()
+		visits = append(visits, visit{r.Request.URL.RequestURI(), r.Request.Header.Get("Referer"), r.Request.Meta})
+	}
+
+	geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartRequestsFunc: func(ctx context.Context, g *geziyor.Geziyor) {
+			req, _ := client.NewRequest(ctx, "GET", ts.URL+"/list/", nil)
+			req.Meta["session"] = "a"
+			req.Meta["page"] = 1
+			g.Do(req, nil)
+		},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			g.FollowAll(ctx, r, r.HTMLDoc.Find("a.item"), record)
+			g.Follow(ctx, r, r.HTMLDoc.Find("a.next"), record)
+		},
+		FollowMetaKeys:    []string{"session"},
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(context.Background())
+
+	referer := ts.URL + "/list/"
+	meta := map[string]interface{}{"session": "a"}
+	assert.ElementsMatch(t, []visit{
+		{"/items/1", referer, meta},
+		{"/items/2", referer, meta},
+		{"/list/?page=2", referer, meta},
+	}, visits)
+}
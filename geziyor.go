@@ -2,24 +2,33 @@ package geziyor
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/chromedp/chromedp"
 	"github.com/toqueteos/geziyor/cache"
 	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/dupefilter"
 	"github.com/toqueteos/geziyor/export"
 	"github.com/toqueteos/geziyor/internal"
 	"github.com/toqueteos/geziyor/metrics"
 	"github.com/toqueteos/geziyor/middleware"
+	"github.com/toqueteos/geziyor/pipeline"
+	"github.com/toqueteos/geziyor/signals"
+	"github.com/toqueteos/geziyor/stats"
 	"golang.org/x/time/rate"
 
 	"io"
 	"io/ioutil"
+	"net/http"
 	"net/http/cookiejar"
 	"os"
 	"os/signal"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 )
 
 // Geziyor is our main scraper type
@@ -27,21 +36,71 @@ type Geziyor struct {
 	Opt     *Options
 	Client  *client.Client
 	Exports chan interface{}
+	Signals *signals.Bus
 
 	metrics        *metrics.Metrics
+	stats          *stats.Stats
 	reqMiddlewares []middleware.RequestProcessor
 	resMiddlewares []middleware.ResponseProcessor
+	itemPipelines  []pipeline.ItemPipeline
 	rateLimiter    *rate.Limiter
+	domains        *domains
+	robots         *middleware.RobotsTxt
+	autoThrottle   *middleware.AutoThrottle
+	feedSeen       dupefilter.DupeFilter
 	wgRequests     sync.WaitGroup
 	wgExporters    sync.WaitGroup
-	semGlobal      chan struct{}
-	semHosts       struct {
-		sync.RWMutex
-		hostSems map[string]chan struct{}
+	scheduler      struct {
+		sync.Mutex
+		cond      *sync.Cond
+		slotFreed *sync.Cond
+		queue     Scheduler
+		slots     map[string]*slot
+		waiting   []*slot
+		wakeTime  time.Time
+		wakeTimer *time.Timer
+		closed    bool
+		workers   sync.WaitGroup
+	}
+	jobDir   *jobDir
+	shutdown atomic.Bool
+	closing  struct {
+		sync.Mutex
+		reason string
+		done   chan struct{}
+	}
+	counters struct {
+		scheduled atomic.Int64
+		items     atomic.Int64
+		pages     atomic.Int64
+		errors    atomic.Int64
 	}
-	shutdown bool
 }
 
+// Reasons of close, returned by Geziyor.Start
+const (
+	// CloseFinished means all requests are processed
+	CloseFinished = "finished"
+	// CloseShutdown means Geziyor.Stop is called
+	CloseShutdown = "shutdown"
+	// CloseCancelled means context is cancelled or SIGINT is received
+	CloseCancelled = "cancelled"
+	// CloseItemCount means Options.CloseOnItemCount is reached
+	CloseItemCount = "itemcount"
+	// ClosePageCount means Options.CloseOnPageCount is reached
+	ClosePageCount = "pagecount"
+	// CloseErrorCount means Options.CloseOnErrorCount is reached
+	CloseErrorCount = "errorcount"
+	// CloseTimeout means Options.CloseOnTimeout is passed
+	CloseTimeout = "timeout"
+	// ClosePipelineError means an item pipeline failed to open, so scraping isn't started
+	ClosePipelineError = "pipelineerror"
+)
+
+// DefaultConcurrentRequests is the number of workers processing scheduled requests
+// if Options.ConcurrentRequests is not set.
+const DefaultConcurrentRequests = 1000
+
 // NewGeziyor creates new Geziyor with default values.
 // If options provided, options
 func NewGeziyor(ctx context.Context, opt *Options) *Geziyor {
@@ -59,41 +118,99 @@ func NewGeziyor(ctx context.Context, opt *Options) *Geziyor {
 	if len(opt.RetryHTTPCodes) == 0 {
 		opt.RetryHTTPCodes = client.DefaultRetryHTTPCodes
 	}
+	if opt.Fingerprinter == nil {
+		opt.Fingerprinter = &client.DefaultFingerprinter{}
+	}
 
 	geziyor := &Geziyor{
 		Opt:     opt,
 		Exports: make(chan interface{}, 1),
-		reqMiddlewares: []middleware.RequestProcessor{
-			&middleware.AllowedDomains{AllowedDomains: opt.AllowedDomains},
-			&middleware.DuplicateRequests{RevisitEnabled: opt.URLRevisitEnabled},
-			&middleware.Headers{UserAgent: opt.UserAgent},
-			middleware.NewDelay(opt.RequestDelayRandomize, opt.RequestDelay),
-		},
-		resMiddlewares: []middleware.ResponseProcessor{
-			&middleware.ParseHTML{ParseHTMLDisabled: opt.ParseHTMLDisabled},
-			&middleware.LogStats{LogDisabled: opt.LogDisabled},
-		},
+		Signals: signals.NewBus(),
 		metrics: metrics.NewMetrics(opt.MetricsType),
+		stats:   stats.New(),
+	}
+
+	// Job directory
+	duplicateRequests := &middleware.DuplicateRequests{
+		RevisitEnabled: opt.URLRevisitEnabled,
+		Fingerprinter:  opt.Fingerprinter,
+		Filter:         opt.DupeFilter,
+		Metrics:        geziyor.metrics,
+	}
+	if opt.JobDir != "" {
+		jobDir, err := openJobDir(opt.JobDir, opt)
+		if err != nil {
+			internal.Logger.Printf("job dir error, crawl state won't be persisted: %v\n", err)
+		} else {
+			geziyor.jobDir = jobDir
+			if duplicateRequests.Filter == nil {
+				duplicateRequests.Filter = jobDir.seen
+			}
+		}
+	}
+	geziyor.feedSeen = opt.FeedSeenFilter
+	if geziyor.feedSeen == nil {
+		if geziyor.jobDir != nil {
+			geziyor.feedSeen = geziyor.jobDir.feeds
+		} else {
+			geziyor.feedSeen = dupefilter.NewMemory()
+		}
 	}
 
+	// Domain settings
+	geziyor.domains = newDomains(opt.DomainSettings)
+
+	// Middlewares
+	metaRobots := &middleware.MetaRobots{UserAgent: opt.RobotsTxtUserAgent, NoFollow: opt.RobotsMetaNoFollowEnabled}
+	geziyor.reqMiddlewares = []middleware.RequestProcessor{
+		&middleware.AllowedDomains{AllowedDomains: opt.AllowedDomains},
+		&middleware.DepthLimit{Limit: opt.DepthLimit},
+		metaRobots,
+		duplicateRequests,
+		&domainSettings{domains: geziyor.domains},
+		&middleware.Headers{UserAgent: opt.UserAgent},
+	}
+	geziyor.resMiddlewares = []middleware.ResponseProcessor{
+		&middleware.ParseHTML{ParseHTMLDisabled: opt.ParseHTMLDisabled},
+		metaRobots,
+	}
+	if opt.RobotsMetaNoIndexEnabled {
+		geziyor.itemPipelines = append(geziyor.itemPipelines, pipeline.NoIndex{})
+	}
+	geziyor.itemPipelines = append(geziyor.itemPipelines, opt.ItemPipelines...)
+
 	// Client
+	proxyFunc := opt.ProxyFunc
+	if len(opt.DomainSettings) != 0 {
+		if proxyFunc == nil {
+			proxyFunc = http.ProxyFromEnvironment
+		}
+		proxyFunc = geziyor.domains.proxyFunc(proxyFunc)
+	}
 	geziyor.Client = client.NewClient(&client.Options{
 		MaxBodySize:           opt.MaxBodySize,
+		WarnBodySize:          opt.WarnBodySize,
 		CharsetDetectDisabled: opt.CharsetDetectDisabled,
 		RetryTimes:            opt.RetryTimes,
 		RetryHTTPCodes:        opt.RetryHTTPCodes,
+		RetryPolicy:           opt.RetryPolicy,
+		RetryPredicate:        opt.RetryPredicate,
 		RemoteAllocatorURL:    opt.BrowserEndpoint,
 		AllocatorOptions:      chromedp.DefaultExecAllocatorOptions[:],
-		ProxyFunc:             opt.ProxyFunc,
+		ProxyFunc:             proxyFunc,
 		PreActions:            opt.PreActions,
 	})
 	if opt.Cache != nil {
-		geziyor.Client.Transport = &cache.Transport{
+		transport := &cache.Transport{
 			Policy:              opt.CachePolicy,
 			Transport:           geziyor.Client.Transport,
 			Cache:               opt.Cache,
 			MarkCachedResponses: true,
 		}
+		if opt.CacheByFingerprint {
+			transport.KeyFunc = opt.Fingerprinter.Fingerprint
+		}
+		geziyor.Client.Transport = transport
 	}
 	if opt.Timeout != 0 {
 		geziyor.Client.Timeout = opt.Timeout
@@ -107,30 +224,64 @@ func NewGeziyor(ctx context.Context, opt *Options) *Geziyor {
 
 	// Concurrency
 	if opt.RequestsPerSecond != 0 {
-		geziyor.rateLimiter = rate.NewLimiter(rate.Limit(opt.RequestsPerSecond), int(opt.RequestsPerSecond))
+		geziyor.rateLimiter = newRateLimiter(opt.RequestsPerSecond)
 	}
-	if opt.ConcurrentRequests != 0 {
-		geziyor.semGlobal = make(chan struct{}, opt.ConcurrentRequests)
+
+	// Scheduler
+	geziyor.scheduler.queue = opt.Scheduler
+	if geziyor.scheduler.queue == nil {
+		geziyor.scheduler.queue = NewPriorityScheduler()
 	}
-	if opt.ConcurrentRequestsPerDomain != 0 {
-		geziyor.semHosts = struct {
-			sync.RWMutex
-			hostSems map[string]chan struct{}
-		}{hostSems: make(map[string]chan struct{})}
+	geziyor.scheduler.cond = sync.NewCond(&geziyor.scheduler)
+	geziyor.scheduler.slotFreed = sync.NewCond(&geziyor.scheduler)
+	geziyor.scheduler.slots = make(map[string]*slot)
+	geziyor.closing.done = make(chan struct{})
+
+	// AutoThrottle
+	if opt.AutoThrottleEnabled {
+		minDelay := opt.AutoThrottleMinDelay
+		if minDelay == 0 {
+			minDelay = opt.RequestDelay
+		}
+		geziyor.autoThrottle = &middleware.AutoThrottle{
+			StartDelay:        opt.AutoThrottleStartDelay,
+			MinDelay:          minDelay,
+			MaxDelay:          opt.AutoThrottleMaxDelay,
+			TargetConcurrency: opt.AutoThrottleTargetConcurrency,
+			Metrics:           geziyor.metrics,
+		}
 	}
 
 	// Base Middlewares
-	metricsMiddleware := &middleware.Metrics{Metrics: geziyor.metrics}
-	geziyor.reqMiddlewares = append(geziyor.reqMiddlewares, metricsMiddleware)
-	geziyor.resMiddlewares = append(geziyor.resMiddlewares, metricsMiddleware)
-
 	robotsMiddleware := middleware.NewRobotsTxt(ctx, geziyor.Client, geziyor.metrics, opt.RobotsTxtDisabled)
+	robotsMiddleware.CrawlDelayDisabled = opt.RobotsTxtCrawlDelayDisabled
+	robotsMiddleware.MaxCrawlDelay = opt.RobotsTxtMaxCrawlDelay
+	robotsMiddleware.IgnoreExcessiveCrawlDelay = opt.RobotsTxtIgnoreExcessiveCrawlDelay
+	robotsMiddleware.Stats = geziyor.stats
+	robotsMiddleware.TTL = opt.RobotsTxtTTL
+	robotsMiddleware.ErrorTTL = opt.RobotsTxtErrorTTL
+	robotsMiddleware.Store = opt.RobotsTxtStore
+	robotsMiddleware.UserAgent = opt.RobotsTxtUserAgent
+	robotsMiddleware.Throttle = slotThrottle{g: geziyor}
 	geziyor.reqMiddlewares = append(geziyor.reqMiddlewares, robotsMiddleware)
+	geziyor.robots = robotsMiddleware
 
 	// Custom Middlewares
 	geziyor.reqMiddlewares = append(geziyor.reqMiddlewares, opt.RequestMiddlewares...)
 	geziyor.resMiddlewares = append(geziyor.resMiddlewares, opt.ResponseMiddlewares...)
 
+	// Extensions
+	extensions := []signals.Extension{
+		&middleware.LogStats{LogDisabled: opt.LogDisabled},
+		&middleware.Metrics{Metrics: geziyor.metrics},
+	}
+	if geziyor.autoThrottle != nil {
+		extensions = append(extensions, geziyor.autoThrottle)
+	}
+	for _, extension := range append(extensions, opt.Extensions...) {
+		extension.Connect(geziyor.Signals)
+	}
+
 	// Logging
 	if opt.LogDisabled {
 		internal.Logger.SetOutput(ioutil.Discard)
@@ -141,13 +292,21 @@ func NewGeziyor(ctx context.Context, opt *Options) *Geziyor {
 	return geziyor
 }
 
-// Start starts scraping
-func (g *Geziyor) Start(ctx context.Context) {
+// Start starts scraping and returns the crawl statistics.
+// Stats.CloseReason is one of the Close* constants.
+func (g *Geziyor) Start(ctx context.Context) *stats.Stats {
 	internal.Logger.Println("Scraping Started")
+	g.stats.Start()
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	// Close conditions
+	if g.Opt.CloseOnTimeout != 0 {
+		timer := time.AfterFunc(g.Opt.CloseOnTimeout, func() { g.close(CloseTimeout) })
+		defer timer.Stop()
+	}
+
 	// Metrics
 	if g.Opt.MetricsType == metrics.Prometheus || g.Opt.MetricsType == metrics.ExpVar {
 		metricsServer := metrics.StartMetricsServer(g.Opt.MetricsType)
@@ -155,225 +314,630 @@ func (g *Geziyor) Start(ctx context.Context) {
 	}
 
 	// Start Exporters
-	g.startExporters()
+	if err := g.openPipelines(ctx); err != nil {
+		internal.Logger.Printf("Scraping Aborted: %v\n", err)
+		if g.jobDir != nil {
+			if err := g.jobDir.close(); err != nil {
+				internal.Logger.Printf("closing job dir: %v\n", err)
+			}
+			g.jobDir = nil
+		}
+		g.stats.Finish(ClosePipelineError)
+		return g.stats
+	}
+	g.startExporters(ctx)
+
+	// Start Workers
+	g.startWorkers()
 
 	// Wait for SIGINT (interrupt) signal.
+	// It doesn't cancel ctx of requests, so that ongoing requests are finished and pending ones are saved.
 	shutdownDoneChan := make(chan struct{})
-	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
-	defer stop()
-	go g.interruptSignalWaiter(ctx, shutdownDoneChan)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go g.interruptSignalWaiter(ctx, cancel, interrupt, shutdownDoneChan)
 
-	// Start Requests
-	if g.Opt.StartRequestsFunc != nil {
-		g.Opt.StartRequestsFunc(ctx, g)
-	} else {
-		for _, startURL := range g.Opt.StartURLs {
-			g.Get(ctx, startURL, g.Opt.ParseFunc)
+	g.Signals.Send(&signals.Event{Signal: signals.SpiderOpened, Context: ctx})
+
+	// Resume stopped crawl or start requests
+	if resumed := g.resume(ctx); !resumed {
+		if g.Opt.StartRequestsFunc != nil {
+			g.Opt.StartRequestsFunc(ctx, g)
+		} else {
+			callback := g.Opt.ParseFunc
+			if len(g.Opt.Rules) != 0 {
+				callback = ParseRules
+			}
+			for _, startURL := range g.Opt.StartURLs {
+				g.Get(ctx, startURL, callback)
+			}
 		}
+		g.startSitemaps(ctx)
+		g.startFeeds(ctx)
 	}
 
-	g.wgRequests.Wait()
+	g.waitIdle(ctx)
+	g.stopWorkers()
 	close(g.Exports)
 	g.wgExporters.Wait()
 	shutdownDoneChan <- struct{}{}
-	internal.Logger.Println("Scraping Finished")
+	g.closeJobDir()
+	g.stats.Finish(g.closeReason())
+	g.Signals.Send(&signals.Event{Signal: signals.SpiderClosed, Context: ctx, Reason: g.stats.CloseReason})
+	internal.Logger.Printf("Scraping Finished (%s)\n%s\n", g.stats.CloseReason, g.stats)
+
+	return g.stats
+}
+
+// waitIdle waits until there are no requests left and sends SpiderIdle.
+// If idle handlers schedule new requests, waits them too.
+func (g *Geziyor) waitIdle(ctx context.Context) {
+	for {
+		g.wgRequests.Wait()
+		if g.shutdown.Load() {
+			return
+		}
+		scheduled := g.counters.scheduled.Load()
+		g.Signals.Send(&signals.Event{Signal: signals.SpiderIdle, Context: ctx})
+		if g.counters.scheduled.Load() == scheduled {
+			return
+		}
+	}
+}
+
+// resume schedules pending requests and restores metrics of the previous run from job dir.
+// Returns true if there were pending requests.
+func (g *Geziyor) resume(ctx context.Context) bool {
+	if g.jobDir == nil {
+		return false
+	}
+	requests, err := g.jobDir.loadRequests(ctx)
+	if err != nil {
+		internal.Logger.Printf("loading pending requests from job dir: %v\n", err)
+	}
+	if len(requests) == 0 {
+		return false
+	}
+	if err := g.jobDir.restoreMetrics(g.metrics); err != nil {
+		internal.Logger.Printf("restoring metrics from job dir: %v\n", err)
+	}
+
+	internal.Logger.Printf("Resuming crawl with %d pending requests\n", len(requests))
+	for _, req := range requests {
+		g.wgRequests.Add(1)
+		g.schedule(req)
+	}
+	return true
+}
+
+// closeJobDir saves metrics to job dir and closes it.
+// Resumed requests are removed from it, as the ones still pending are saved again.
+func (g *Geziyor) closeJobDir() {
+	if g.jobDir == nil {
+		return
+	}
+	if err := g.jobDir.removeLoaded(); err != nil {
+		internal.Logger.Printf("removing resumed requests from job dir: %v\n", err)
+	}
+	if err := g.jobDir.saveMetrics(g.metrics); err != nil {
+		internal.Logger.Printf("saving metrics to job dir: %v\n", err)
+	}
+	if err := g.jobDir.close(); err != nil {
+		internal.Logger.Printf("closing job dir: %v\n", err)
+	}
+	g.jobDir = nil
 }
 
-// Stop disables any more requests and signals all currently ongoing requests to finish
+// Stop disables any more requests and signals all currently ongoing requests to finish.
+// If Options.JobDir is set, requests that are not made yet are saved to be resumed on next start.
 func (g *Geziyor) Stop() {
-	g.shutdown = true
+	g.close(CloseShutdown)
+}
+
+// close starts graceful shutdown with reason. Only the first reason is kept.
+func (g *Geziyor) close(reason string) {
+	g.closing.Lock()
+	defer g.closing.Unlock()
+	if g.closing.reason == "" {
+		g.closing.reason = reason
+		internal.Logger.Printf("Closing scraper (%s)\n", reason)
+		close(g.closing.done)
+	}
+	g.shutdown.Store(true)
+
+	// Wake up workers to save requests waiting in slots
+	g.scheduler.cond.Broadcast()
+}
+
+// closeReason returns the reason of close, CloseFinished if it's not closed.
+func (g *Geziyor) closeReason() string {
+	g.closing.Lock()
+	defer g.closing.Unlock()
+	if g.closing.reason == "" {
+		return CloseFinished
+	}
+	return g.closing.reason
+}
+
+// countItem counts exported items and closes if CloseOnItemCount is reached
+func (g *Geziyor) countItem() {
+	g.stats.RecordItem()
+	if n := g.counters.items.Add(1); g.Opt.CloseOnItemCount > 0 && n >= int64(g.Opt.CloseOnItemCount) {
+		g.close(CloseItemCount)
+	}
+}
+
+// countPage counts received responses and closes if CloseOnPageCount is reached
+func (g *Geziyor) countPage() {
+	if n := g.counters.pages.Add(1); g.Opt.CloseOnPageCount > 0 && n >= int64(g.Opt.CloseOnPageCount) {
+		g.close(ClosePageCount)
+	}
+}
+
+// countError counts request errors and closes if CloseOnErrorCount is reached
+func (g *Geziyor) countError() {
+	if n := g.counters.errors.Add(1); g.Opt.CloseOnErrorCount > 0 && n >= int64(g.Opt.CloseOnErrorCount) {
+		g.close(CloseErrorCount)
+	}
 }
 
 // Get issues a GET to the specified URL.
-func (g *Geziyor) Get(ctx context.Context, url string, callback ParseFunc) {
+func (g *Geziyor) Get(ctx context.Context, url string, callback ParseFunc, opts ...RequestOption) {
 	req, err := client.NewRequest(ctx, "GET", url, nil)
 	if err != nil {
 		internal.Logger.Printf("Request creating error %v\n", err)
 		return
 	}
-	g.Do(req, callback)
+	g.Do(req, callback, opts...)
 }
 
 // GetRendered issues GET request using headless browser
 // Opens up a new Chrome instance, makes request, waits for rendering HTML DOM and closed.
 // Rendered requests only supported for GET requests.
-func (g *Geziyor) GetRendered(ctx context.Context, url string, callback ParseFunc) {
+func (g *Geziyor) GetRendered(ctx context.Context, url string, callback ParseFunc, opts ...RequestOption) {
 	req, err := client.NewRequest(ctx, "GET", url, nil)
 	if err != nil {
 		internal.Logger.Printf("Request creating error %v\n", err)
 		return
 	}
 	req.Rendered = true
-	g.Do(req, callback)
+	g.Do(req, callback, opts...)
 }
 
 // Head issues a HEAD to the specified URL
-func (g *Geziyor) Head(ctx context.Context, url string, callback ParseFunc) {
+func (g *Geziyor) Head(ctx context.Context, url string, callback ParseFunc, opts ...RequestOption) {
 	req, err := client.NewRequest(ctx, "HEAD", url, nil)
 	if err != nil {
 		internal.Logger.Printf("Request creating error %v\n", err)
 		return
 	}
-	g.Do(req, callback)
+	g.Do(req, callback, opts...)
 }
 
 // Post issues a POST to the specified URL
-func (g *Geziyor) Post(ctx context.Context, url string, body io.Reader, callback ParseFunc) {
+func (g *Geziyor) Post(ctx context.Context, url string, body io.Reader, callback ParseFunc, opts ...RequestOption) {
 	req, err := client.NewRequest(ctx, "POST", url, body)
 	if err != nil {
 		internal.Logger.Printf("Request creating error %v\n", err)
 		return
 	}
-	g.Do(req, callback)
+	g.Do(req, callback, opts...)
 }
 
-// Do sends an HTTP request
-func (g *Geziyor) Do(req *client.Request, callback ParseFunc) {
-	if g.shutdown {
+// Do sends an HTTP request.
+// Synchronized requests are made immediately, others are pushed to the scheduler.
+func (g *Geziyor) Do(req *client.Request, callback ParseFunc, opts ...RequestOption) {
+	scheduled := &ScheduledRequest{Request: req, Callback: callback}
+	for _, opt := range opts {
+		opt(scheduled)
+	}
+	if g.shutdown.Load() {
+		g.savePending(scheduled)
 		return
 	}
+	if g.Opt.DepthPriority != 0 {
+		req.Priority -= req.Depth() * g.Opt.DepthPriority
+	}
+	g.counters.scheduled.Add(1)
 	g.wgRequests.Add(1)
+	g.Signals.Send(&signals.Event{Signal: signals.RequestScheduled, Context: req.Context(), Request: req})
 	if req.Synchronized {
-		g.do(req, callback)
+		g.do(scheduled)
 	} else {
-		go g.do(req, callback)
+		g.schedule(scheduled)
 	}
 }
 
-// Do sends an HTTP request
-func (g *Geziyor) do(req *client.Request, callback ParseFunc) {
-	g.acquireSem(req)
-	defer g.releaseSem(req)
-	defer g.wgRequests.Done()
-	defer g.recoverMe()
+// savePending saves request that won't be made because of shutdown to job dir, if it's set.
+func (g *Geziyor) savePending(req *ScheduledRequest) {
+	if g.jobDir == nil {
+		return
+	}
+	if err := g.jobDir.saveRequest(req); err != nil {
+		internal.Logger.Printf("saving pending request to job dir: %v\n", err)
+	}
+}
 
-	for _, middlewareFunc := range g.reqMiddlewares {
-		middlewareFunc.ProcessRequest(req)
-		if req.Cancelled {
-			return
+// schedule pushes request to the scheduler and wakes up a waiting worker
+func (g *Geziyor) schedule(req *ScheduledRequest) {
+	g.scheduler.Lock()
+	g.scheduler.queue.Enqueue(req)
+	g.scheduler.Unlock()
+	g.scheduler.cond.Signal()
+}
+
+// next blocks until there is a scheduled request that can be made now and reserves its host slot.
+// Requests of busy or delayed hosts wait in their slots meanwhile, so that they don't hold workers.
+// Returns nil if workers are stopped.
+// Requests left in the scheduler after shutdown are discarded or saved to job dir.
+func (g *Geziyor) next() *ScheduledRequest {
+	g.scheduler.Lock()
+	defer g.scheduler.Unlock()
+	for {
+		if g.shutdown.Load() {
+			g.discardPending()
+		}
+		if req := g.dispatch(time.Now()); req != nil {
+			// Let another worker look for a request that can be made too
+			g.scheduler.cond.Signal()
+			return req
+		}
+		if g.scheduler.closed {
+			return nil
+		}
+		g.scheduler.cond.Wait()
+	}
+}
+
+// discardPending discards requests of scheduler and slots, or saves them to job dir.
+// Must be called with scheduler lock held.
+func (g *Geziyor) discardPending() {
+	for _, s := range g.scheduler.waiting {
+		for req := s.waiting.Next(); req != nil; req = s.waiting.Next() {
+			g.savePending(req)
+			g.wgRequests.Done()
 		}
 	}
+	g.scheduler.waiting = nil
+	for req := g.scheduler.queue.Next(); req != nil; req = g.scheduler.queue.Next() {
+		g.savePending(req)
+		g.wgRequests.Done()
+	}
+}
+
+// startWorkers starts fixed number of workers which process scheduled requests
+func (g *Geziyor) startWorkers() {
+	workers := g.Opt.ConcurrentRequests
+	if workers == 0 {
+		workers = DefaultConcurrentRequests
+	}
+
+	g.scheduler.Lock()
+	g.scheduler.closed = false
+	g.scheduler.Unlock()
+
+	g.scheduler.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer g.scheduler.workers.Done()
+			for req := g.next(); req != nil; req = g.next() {
+				g.do(req)
+			}
+		}()
+	}
+}
+
+// stopWorkers wakes up all waiting workers and waits them to exit
+func (g *Geziyor) stopWorkers() {
+	g.scheduler.Lock()
+	g.scheduler.closed = true
+	if g.scheduler.wakeTimer != nil {
+		g.scheduler.wakeTimer.Stop()
+	}
+	g.scheduler.Unlock()
+	g.scheduler.cond.Broadcast()
+	g.scheduler.workers.Wait()
+}
 
-	res, err := g.Client.DoRequest(req)
+// do processes request and calls its callbacks.
+// Scheduled requests have their host slot reserved by workers, synchronized requests wait for it here.
+func (g *Geziyor) do(scheduled *ScheduledRequest) {
+	req := scheduled.Request
+	defer g.wgRequests.Done()
+	defer g.recoverMe(req)
+
+	if req.Synchronized {
+		g.waitSlot(scheduled)
+	}
+	res, err := g.download(scheduled)
 	if err != nil {
-		if g.Opt.ErrorFunc != nil {
+		var reqErr *client.Error
+		if g.shutdown.Load() && errors.As(err, &reqErr) && reqErr.Kind == client.KindCancelled {
+			// Request is cancelled by a forced shutdown, save it to be made again on next start.
+			// Error is still sent, so that request is closed for signal listeners like AutoThrottle.
+			g.Signals.Send(&signals.Event{Signal: signals.ErrorRaised, Context: req.Context(), Request: req, Err: err})
+			req.DontFilter = true
+			g.savePending(scheduled)
+			return
+		}
+		g.stats.RecordError(req, err)
+		g.countError()
+		g.Signals.Send(&signals.Event{Signal: signals.ErrorRaised, Context: req.Context(), Request: req, Err: err})
+		if scheduled.Errback != nil {
+			scheduled.Errback(req.Context(), g, req, err)
+		} else if g.Opt.ErrorFunc != nil {
 			g.Opt.ErrorFunc(req.Context(), g, req, err)
 		} else {
 			internal.Logger.Println(err)
 		}
 		return
 	}
+	if res == nil {
+		return
+	}
+	g.stats.RecordResponse(res)
+	g.countPage()
 
 	for _, middlewareFunc := range g.resMiddlewares {
 		middlewareFunc.ProcessResponse(res)
 	}
+	g.Signals.Send(&signals.Event{Signal: signals.ResponseReceived, Context: req.Context(), Request: req, Response: res})
 
 	// Callbacks
-	if callback != nil {
-		callback(req.Context(), g, res)
+	if res.BodyStream != nil {
+		defer res.BodyStream.Close()
+	}
+	ctx := client.ContextWithResponse(req.Context(), res)
+	if scheduled.Callback != nil {
+		scheduled.Callback(ctx, g, res)
 	} else {
 		if g.Opt.ParseFunc != nil {
-			g.Opt.ParseFunc(req.Context(), g, res)
+			g.Opt.ParseFunc(ctx, g, res)
 		}
 	}
 }
 
-func (g *Geziyor) acquireSem(req *client.Request) {
-	if g.rateLimiter != nil {
-		_ = g.rateLimiter.Wait(req.Context())
-	}
-	if g.Opt.ConcurrentRequests != 0 {
-		g.semGlobal <- struct{}{}
+// download passes request through request middlewares and makes it, retrying synchronized requests.
+// Host slot of request is released when it returns, before callbacks are called.
+// Returns nil response and error if request is dropped or scheduled to be retried.
+func (g *Geziyor) download(scheduled *ScheduledRequest) (*client.Response, error) {
+	req := scheduled.Request
+	defer g.releaseSlot(scheduled, false)
+
+	for _, middlewareFunc := range g.reqMiddlewares {
+		middlewareFunc.ProcessRequest(req)
+		if req.Cancelled {
+			g.releaseSlot(scheduled, true)
+			name := typeName(middlewareFunc)
+			g.stats.RecordDropped(req, name)
+			g.Signals.Send(&signals.Event{Signal: signals.RequestDropped, Context: req.Context(), Request: req, Reason: name})
+			if scheduled.Errback != nil {
+				err := &client.Error{Kind: client.KindCancelled, Request: req, Err: fmt.Errorf("%w by %s", client.ErrRequestCancelled, name)}
+				scheduled.Errback(req.Context(), g, req, err)
+			}
+			return nil, nil
+		}
 	}
-	if g.Opt.ConcurrentRequestsPerDomain != 0 {
-		g.semHosts.RLock()
-		hostSem, exists := g.semHosts.hostSems[req.Host]
-		g.semHosts.RUnlock()
-		if !exists {
-			hostSem = make(chan struct{}, g.Opt.ConcurrentRequestsPerDomain)
-			g.semHosts.Lock()
-			g.semHosts.hostSems[req.Host] = hostSem
-			g.semHosts.Unlock()
+	g.probeSlot(scheduled)
+
+	g.stats.RecordRequest(req)
+	for {
+		g.Signals.Send(&signals.Event{Signal: signals.RequestReachedDownloader, Context: req.Context(), Request: req})
+		res, err := g.Client.DoRequestOnce(req)
+		if err == nil {
+			return res, nil
+		}
+		delay, retry := g.Client.Retry(req, err)
+		if !retry {
+			return nil, err
 		}
-		hostSem <- struct{}{}
+		g.countRetry(req, err)
+		if !req.Synchronized {
+			g.scheduleRetry(scheduled, delay)
+			return nil, nil
+		}
+		g.waitRetry(req, delay)
 	}
 }
 
-func (g *Geziyor) releaseSem(req *client.Request) {
-	if g.Opt.ConcurrentRequests != 0 {
-		<-g.semGlobal
+// countRetry marks request as retried and counts it by reason
+func (g *Geziyor) countRetry(req *client.Request, err error) {
+	req.RetryCountInc()
+	req.DontFilter = true
+	reason := client.KindOther
+	var reqErr *client.Error
+	if errors.As(err, &reqErr) {
+		reason = reqErr.Kind
+	}
+	g.metrics.RetryCounter.With("reason", reason.String()).Add(1)
+	g.Signals.Send(&signals.Event{Signal: signals.RequestRetried, Context: req.Context(), Request: req, Err: err})
+	internal.Logger.Println("Retrying:", req.URL.String(), err)
+}
+
+// scheduleRetry pushes request to the scheduler again after delay.
+// Requests waiting to be retried are scheduled immediately on shutdown, so they can be saved to job dir.
+func (g *Geziyor) scheduleRetry(req *ScheduledRequest, delay time.Duration) {
+	if g.shutdown.Load() {
+		g.savePending(req)
+		return
 	}
-	if g.Opt.ConcurrentRequestsPerDomain != 0 {
-		g.semHosts.RLock()
-		hostSem := g.semHosts.hostSems[req.Host]
-		g.semHosts.RUnlock()
-		<-hostSem
+	g.wgRequests.Add(1)
+	go func() {
+		g.waitRetry(req.Request, delay)
+		g.schedule(req)
+	}()
+}
+
+// waitRetry waits delay before retrying request, unless scraper is closed or request is cancelled
+func (g *Geziyor) waitRetry(req *client.Request, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-g.closing.done:
+	case <-req.Context().Done():
 	}
 }
 
+// typeName returns type name of v without package
+func typeName(v interface{}) string {
+	name := fmt.Sprintf("%T", v)
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
 // recoverMe prevents scraping being crashed.
 // Logs error and stack trace
-func (g *Geziyor) recoverMe() {
+func (g *Geziyor) recoverMe(req *client.Request) {
 	if r := recover(); r != nil {
 		internal.Logger.Println(r, string(debug.Stack()))
 		g.metrics.PanicCounter.Add(1)
+		g.Signals.Send(&signals.Event{Signal: signals.ErrorRaised, Context: req.Context(), Request: req, Err: fmt.Errorf("panic: %v", r)})
 	}
 }
 
-// interruptSignalWaiter waits data from provided channels and stops scraper if shutdownChan channel receives SIGINT
-func (g *Geziyor) interruptSignalWaiter(ctx context.Context, shutdownDoneChan chan struct{}) {
+// interruptSignalWaiter waits data from provided channels and stops scraper if interrupt channel receives SIGINT.
+// The first SIGINT shuts down gracefully, the second one cancels ongoing requests.
+func (g *Geziyor) interruptSignalWaiter(ctx context.Context, cancel context.CancelFunc, interrupt chan os.Signal, shutdownDoneChan chan struct{}) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
+	done := ctx.Done()
+	interrupted := false
 	for {
 		select {
 		case <-ticker.C:
 			// tick
-		case <-ctx.Done():
+		case <-interrupt:
+			if interrupted {
+				internal.Logger.Println("Received SIGINT again, cancelling ongoing requests")
+				cancel()
+				continue
+			}
 			internal.Logger.Println("Received SIGINT, shutting down gracefully. Send again to force")
-			g.shutdown = true
+			g.close(CloseCancelled)
+			interrupted = true
+		case <-done:
+			g.close(CloseCancelled)
+			done = nil
 		case <-shutdownDoneChan:
 			return
 		}
 	}
 }
 
-func (g *Geziyor) startExporters() {
-	if len(g.Opt.Exporters) != 0 {
-		var exporterChans []chan interface{}
+// exportedItem is an item sent to Exports by Export, with context of its callback
+type exportedItem struct {
+	ctx  context.Context
+	item interface{}
+}
 
-		g.wgExporters.Add(len(g.Opt.Exporters))
-		for _, exporter := range g.Opt.Exporters {
-			exporterChan := make(chan interface{})
-			exporterChans = append(exporterChans, exporterChan)
-			go func(exporter export.Exporter) {
-				defer g.wgExporters.Done()
-				if err := exporter.Export(exporterChan); err != nil {
-					internal.Logger.Printf("exporter error: %s\n", err)
-				}
-			}(exporter)
-		}
-		go func() {
-			// When exports closed, close the exporter chans.
-			// Exports chan will be closed after all requests are handled.
-			defer func() {
-				for _, exporterChan := range exporterChans {
-					close(exporterChan)
-				}
-			}()
-			// Send incoming data from exports to all of the exporter's chans
-			for data := range g.Exports {
-				for _, exporterChan := range exporterChans {
-					exporterChan <- data
-				}
+// Export sends item to exporters, like sending it to Exports channel.
+// ctx should be the context of callback, so that item pipelines know which response item is scraped from.
+func (g *Geziyor) Export(ctx context.Context, item interface{}) {
+	g.Exports <- &exportedItem{ctx: ctx, item: item}
+}
+
+func (g *Geziyor) startExporters(ctx context.Context) {
+	var exporterChans []chan interface{}
+
+	g.wgExporters.Add(len(g.Opt.Exporters) + 1)
+	for _, exporter := range g.Opt.Exporters {
+		exporterChan := make(chan interface{})
+		exporterChans = append(exporterChans, exporterChan)
+		go func(exporter export.Exporter) {
+			defer g.wgExporters.Done()
+			if err := exporter.Export(exporterChan); err != nil {
+				internal.Logger.Printf("exporter error: %s\n", err)
 			}
-		}()
-	} else {
-		g.wgExporters.Add(1)
-		go func() {
-			for range g.Exports {
+		}(exporter)
+	}
+	go func() {
+		defer g.wgExporters.Done()
+		// When exports closed, close the exporter chans.
+		// Exports chan will be closed after all requests are handled.
+		defer func() {
+			for _, exporterChan := range exporterChans {
+				close(exporterChan)
 			}
-			g.wgExporters.Done()
 		}()
+		defer g.closePipelines(ctx)
+		// Send incoming data from exports to all of the exporter's chans
+		warnedOrigin := false
+		for data := range g.Exports {
+			itemCtx := ctx
+			if exported, ok := data.(*exportedItem); ok {
+				itemCtx, data = exported.ctx, exported.item
+			} else if g.Opt.RobotsMetaNoIndexEnabled && !warnedOrigin {
+				// Response of items sent to Exports is unknown
+				internal.Logger.Println("Items sent to Exports channel aren't checked for noindex, use Geziyor.Export instead")
+				warnedOrigin = true
+			}
+			data, ok := g.processItem(itemCtx, data)
+			if !ok {
+				continue
+			}
+			g.countItem()
+			g.Signals.Send(&signals.Event{Signal: signals.ItemScraped, Context: itemCtx, Item: data})
+			for _, exporterChan := range exporterChans {
+				exporterChan <- data
+			}
+		}
+	}()
+}
+
+// openPipelines calls Open of item pipelines implementing pipeline.Opener.
+// If one fails, pipelines opened before it are closed and its error is returned.
+func (g *Geziyor) openPipelines(ctx context.Context) error {
+	for i, p := range g.itemPipelines {
+		if opener, ok := p.(pipeline.Opener); ok {
+			if err := opener.Open(ctx); err != nil {
+				closePipelines(ctx, g.itemPipelines[:i])
+				return fmt.Errorf("item pipeline %s open error: %w", pipelineName(p), err)
+			}
+		}
+	}
+	return nil
+}
+
+// closePipelines calls Close of item pipelines implementing pipeline.Closer
+func (g *Geziyor) closePipelines(ctx context.Context) {
+	closePipelines(ctx, g.itemPipelines)
+}
+
+func closePipelines(ctx context.Context, pipelines []pipeline.ItemPipeline) {
+	for _, p := range pipelines {
+		if closer, ok := p.(pipeline.Closer); ok {
+			if err := closer.Close(ctx); err != nil {
+				internal.Logger.Printf("item pipeline %s close error: %v\n", pipelineName(p), err)
+			}
+		}
+	}
+}
+
+// pipelineName returns name of item pipeline p for logs, metrics and signals, see pipeline.Namer
+func pipelineName(p pipeline.ItemPipeline) string {
+	if namer, ok := p.(pipeline.Namer); ok {
+		return namer.Name()
+	}
+	return typeName(p)
+}
+
+// processItem passes item through item pipelines in order.
+// Returns false if item is dropped by a pipeline.
+func (g *Geziyor) processItem(ctx context.Context, item interface{}) (interface{}, bool) {
+	for _, p := range g.itemPipelines {
+		processed, err := p.ProcessItem(ctx, item)
+		if err != nil {
+			name := pipelineName(p)
+			if !errors.Is(err, pipeline.DropItem) {
+				internal.Logger.Printf("item pipeline %s error: %v\n", name, err)
+			}
+			g.metrics.ItemDroppedCounter.With("pipeline", name).Add(1)
+			g.Signals.Send(&signals.Event{Signal: signals.ItemDropped, Context: ctx, Item: item, Err: err, Reason: name})
+			return nil, false
+		}
+		item = processed
 	}
+	return item, true
 }
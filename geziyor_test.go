@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -72,7 +73,7 @@ func TestQuotes(t *testing.T) {
 	geziyor.NewGeziyor(ctx, &geziyor.Options{
 		StartURLs: []string{"http://quotes.toscrape.com/"},
 		ParseFunc: quotesParse,
-		Exporters: []export.Exporter{&export.JSONLine{FileName: "1.jsonl"}, &export.JSON{FileName: "2.json"}},
+		Exporters: []export.Exporter{&export.JSONLine{FileName: filepath.Join(t.TempDir(), "1.jsonl")}, &export.JSON{FileName: filepath.Join(t.TempDir(), "2.json")}},
 	}).Start(ctx)
 }
 
@@ -115,7 +116,7 @@ func TestAllLinks(t *testing.T) {
 				}
 			})
 		},
-		Exporters:   []export.Exporter{&export.CSV{}},
+		Exporters:   []export.Exporter{&export.CSV{FileName: filepath.Join(t.TempDir(), "out.csv")}},
 		MetricsType: metrics.Prometheus,
 	}).Start(ctx)
 }
@@ -131,7 +132,7 @@ func TestStartRequestsFunc(t *testing.T) {
 				g.Exports <- s.AttrOr("href", "")
 			})
 		},
-		Exporters: []export.Exporter{&export.JSON{}},
+		Exporters: []export.Exporter{&export.JSON{FileName: filepath.Join(t.TempDir(), "out.json")}},
 	}).Start(ctx)
 }
 
@@ -236,7 +237,7 @@ type PostBody struct {
 	Message  string `json:"message"`
 }
 
-func TestPostJson(_ *testing.T) {
+func TestPostJson(t *testing.T) {
 	postBody := &PostBody{
 		UserName: "Juan Valdez",
 		Message:  "Best coffee in town",
@@ -253,11 +254,11 @@ func TestPostJson(_ *testing.T) {
 			fmt.Println(string(r.Body))
 			g.Exports <- string(r.Body)
 		},
-		Exporters: []export.Exporter{&export.JSON{FileName: "post_json.json"}},
+		Exporters: []export.Exporter{&export.JSON{FileName: filepath.Join(t.TempDir(), "post_json.json")}},
 	}).Start(ctx)
 }
 
-func TestPostFormUrlEncoded(_ *testing.T) {
+func TestPostFormUrlEncoded(t *testing.T) {
 	var postForm url.Values
 	postForm.Set("user_name", "Juan Valdez")
 	postForm.Set("message", "Enjoy a good coffee!")
@@ -275,7 +276,7 @@ func TestPostFormUrlEncoded(_ *testing.T) {
 				"entire_response": string(r.Body),
 			}
 		},
-		Exporters: []export.Exporter{&export.JSON{FileName: "post_form.json"}},
+		Exporters: []export.Exporter{&export.JSON{FileName: filepath.Join(t.TempDir(), "post_form.json")}},
 	}).Start(ctx)
 }
 
@@ -447,7 +448,7 @@ func BenchmarkWhole(b *testing.B) {
 					}
 				})
 			},
-			Exporters: []export.Exporter{&export.CSV{}},
+			Exporters: []export.Exporter{&export.CSV{FileName: filepath.Join(b.TempDir(), "out.csv")}},
 			//MetricsType: metrics.Prometheus,
 			LogDisabled: true,
 		}).Start(ctx)
@@ -0,0 +1,280 @@
+package geziyor
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/dupefilter"
+	"github.com/toqueteos/geziyor/internal"
+	"github.com/toqueteos/geziyor/metrics"
+)
+
+// jobDir persists crawl state to a directory, so that a stopped crawl can be resumed.
+//
+//	requests/     pending requests
+//	seen/         fingerprints of visited requests
+//	feeds/        GUIDs of seen feed entries
+//	metrics.json  metrics counter totals
+type jobDir struct {
+	path      string
+	requests  *leveldb.DB
+	seen      *dupefilter.LevelDB
+	feeds     *dupefilter.LevelDB
+	opt       *Options
+	mut       sync.Mutex
+	seq       uint64
+	loaded    [][]byte
+	callbacks map[uintptr]string
+	errbacks  map[uintptr]string
+}
+
+// closureNameRe matches names of function literals, like "main.run.func1"
+var closureNameRe = regexp.MustCompile(`\.func\d+(\.\d+)*$`)
+
+// isClosure reports whether fn is a function literal or a method value.
+// They can't be registered as callbacks, as every closure of the same literal or method has the same code pointer.
+func isClosure(fn interface{}) bool {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return false
+	}
+	return strings.HasSuffix(f.Name(), "-fm") || closureNameRe.MatchString(f.Name())
+}
+
+// register adds name of fn to names, rejecting closures and functions registered with another name
+func register(names map[uintptr]string, name string, fn interface{}) {
+	if isClosure(fn) {
+		internal.Logger.Printf("%q is a closure, only named functions can be restored from job dir\n", name)
+		return
+	}
+	pointer := reflect.ValueOf(fn).Pointer()
+	if other, exists := names[pointer]; exists {
+		internal.Logger.Printf("%q is the same function as %q, only one of them can be restored from job dir\n", name, other)
+		return
+	}
+	names[pointer] = name
+}
+
+// pendingRequest is the serializable form of ScheduledRequest
+type pendingRequest struct {
+	Callback string          `json:"callback,omitempty"`
+	Errback  string          `json:"errback,omitempty"`
+	Request  json.RawMessage `json:"request"`
+}
+
+func openJobDir(path string, opt *Options) (*jobDir, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("creating job dir: %w", err)
+	}
+	requests, err := leveldb.OpenFile(filepath.Join(path, "requests"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening requests db: %w", err)
+	}
+	seen, err := dupefilter.NewLevelDB(filepath.Join(path, "seen"))
+	if err != nil {
+		requests.Close()
+		return nil, fmt.Errorf("opening seen db: %w", err)
+	}
+	feeds, err := dupefilter.NewLevelDB(filepath.Join(path, "feeds"))
+	if err != nil {
+		requests.Close()
+		seen.Close()
+		return nil, fmt.Errorf("opening feeds db: %w", err)
+	}
+
+	j := &jobDir{
+		path:      path,
+		requests:  requests,
+		seen:      seen,
+		feeds:     feeds,
+		opt:       opt,
+		callbacks: make(map[uintptr]string),
+		errbacks:  make(map[uintptr]string),
+	}
+	for name, callback := range opt.Callbacks {
+		register(j.callbacks, name, callback)
+	}
+	j.callbacks[reflect.ValueOf(parseSitemap).Pointer()] = sitemapCallback
+	j.callbacks[reflect.ValueOf(parseFeed).Pointer()] = feedCallback
+	j.callbacks[reflect.ValueOf(parseFeedEntry).Pointer()] = feedEntryCallback
+	j.callbacks[reflect.ValueOf(ParseRules).Pointer()] = rulesCallback
+	for name, errback := range opt.Errbacks {
+		register(j.errbacks, name, errback)
+	}
+
+	// Continue sequence after last stored request
+	iter := requests.NewIterator(nil, nil)
+	if iter.Last() {
+		j.seq = binary.BigEndian.Uint64(iter.Key()) + 1
+	}
+	iter.Release()
+
+	return j, nil
+}
+
+// callbackName finds the registered name of callback.
+// Empty name is used for Options.ParseFunc.
+func (j *jobDir) callbackName(callback ParseFunc) (string, error) {
+	if callback == nil {
+		return "", nil
+	}
+	if isClosure(callback) {
+		return "", errors.New("callback is a closure")
+	}
+	pointer := reflect.ValueOf(callback).Pointer()
+	if name, exists := j.callbacks[pointer]; exists {
+		return name, nil
+	}
+	if j.opt.ParseFunc != nil && reflect.ValueOf(j.opt.ParseFunc).Pointer() == pointer {
+		return "", nil
+	}
+	return "", errors.New("callback is not registered in Options.Callbacks")
+}
+
+// errbackName finds the registered name of errback.
+// Empty name is used for Options.ErrorFunc.
+func (j *jobDir) errbackName(errback ErrorFunc) (string, error) {
+	if errback == nil {
+		return "", nil
+	}
+	if isClosure(errback) {
+		return "", errors.New("errback is a closure")
+	}
+	pointer := reflect.ValueOf(errback).Pointer()
+	if name, exists := j.errbacks[pointer]; exists {
+		return name, nil
+	}
+	if j.opt.ErrorFunc != nil && reflect.ValueOf(j.opt.ErrorFunc).Pointer() == pointer {
+		return "", nil
+	}
+	return "", errors.New("errback is not registered in Options.Errbacks")
+}
+
+// saveRequest stores request to be scheduled on next run
+func (j *jobDir) saveRequest(req *ScheduledRequest) error {
+	callback, err := j.callbackName(req.Callback)
+	if err != nil {
+		internal.Logger.Printf("%v, Options.ParseFunc will be used for %s\n", err, req.URL.String())
+	}
+	errback, err := j.errbackName(req.Errback)
+	if err != nil {
+		internal.Logger.Printf("%v, Options.ErrorFunc will be used for %s\n", err, req.URL.String())
+	}
+	reqData, err := req.Marshal()
+	if err != nil {
+		return fmt.Errorf("marshaling request: %w", err)
+	}
+	data, err := json.Marshal(pendingRequest{Callback: callback, Errback: errback, Request: reqData})
+	if err != nil {
+		return fmt.Errorf("marshaling pending request: %w", err)
+	}
+
+	j.mut.Lock()
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, j.seq)
+	j.seq++
+	j.mut.Unlock()
+
+	return j.requests.Put(key, data, nil)
+}
+
+// loadRequests returns stored requests in saved order.
+// They're kept in the job dir until it's closed, so that they're resumed again if the crawl crashes.
+func (j *jobDir) loadRequests(ctx context.Context) ([]*ScheduledRequest, error) {
+	var requests []*ScheduledRequest
+
+	iter := j.requests.NewIterator(nil, nil)
+	for iter.Next() {
+		j.loaded = append(j.loaded, append([]byte(nil), iter.Key()...))
+
+		var pending pendingRequest
+		if err := json.Unmarshal(iter.Value(), &pending); err != nil {
+			internal.Logger.Printf("pending request decoding error: %v\n", err)
+			continue
+		}
+		req, err := client.UnmarshalRequest(ctx, pending.Request)
+		if err != nil {
+			internal.Logger.Printf("pending request decoding error: %v\n", err)
+			continue
+		}
+		callback, exists := j.opt.Callbacks[pending.Callback]
+		switch pending.Callback {
+		case sitemapCallback:
+			callback, exists = parseSitemap, true
+		case feedCallback:
+			callback, exists = parseFeed, true
+		case feedEntryCallback:
+			callback, exists = parseFeedEntry, true
+		case rulesCallback:
+			callback, exists = ParseRules, true
+		}
+		if !exists && pending.Callback != "" {
+			internal.Logger.Printf("callback %q is not registered, Options.ParseFunc will be used for %s\n", pending.Callback, req.URL.String())
+		}
+		errback, exists := j.opt.Errbacks[pending.Errback]
+		if !exists && pending.Errback != "" {
+			internal.Logger.Printf("errback %q is not registered, Options.ErrorFunc will be used for %s\n", pending.Errback, req.URL.String())
+		}
+		requests = append(requests, &ScheduledRequest{Request: req, Callback: callback, Errback: errback})
+	}
+	iter.Release()
+	return requests, iter.Error()
+}
+
+// removeLoaded removes requests returned by loadRequests.
+// Requests that are still pending are saved again before it's called.
+func (j *jobDir) removeLoaded() error {
+	batch := new(leveldb.Batch)
+	for _, key := range j.loaded {
+		batch.Delete(key)
+	}
+	j.loaded = nil
+	return j.requests.Write(batch, nil)
+}
+
+func (j *jobDir) metricsPath() string {
+	return filepath.Join(j.path, "metrics.json")
+}
+
+// saveMetrics stores metrics counter totals
+func (j *jobDir) saveMetrics(m *metrics.Metrics) error {
+	data, err := json.Marshal(m.CounterValues())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(j.metricsPath(), data, 0644)
+}
+
+// restoreMetrics adds stored metrics counter totals to counters.
+// It's only called when pending requests are resumed, as totals are of the stopped crawl.
+func (j *jobDir) restoreMetrics(m *metrics.Metrics) error {
+	data, err := os.ReadFile(j.metricsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var values []metrics.CounterValue
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	m.RestoreCounterValues(values)
+	return nil
+}
+
+func (j *jobDir) close() error {
+	return errors.Join(j.requests.Close(), j.seen.Close(), j.feeds.Close())
+}
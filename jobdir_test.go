@@ -0,0 +1,110 @@
+package geziyor_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+)
+
+// Callbacks of resumed requests must be named functions, so state of jobDirDetail is kept in package
+var (
+	jobDirMut     sync.Mutex
+	jobDirVisited []string
+	jobDirURL     string
+)
+
+func jobDirDetail(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+	jobDirMut.Lock()
+	jobDirVisited = append(jobDirVisited, r.Request.URL.Path)
+	jobDirMut.Unlock()
+	// Already visited on first run
+	g.Get(ctx, jobDirURL+"/start", nil)
+}
+
+func TestJobDirResume(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	jobDirURL = ts.URL
+
+	jobDir := t.TempDir()
+	ctx := context.Background()
+
+	newGeziyor := func() *geziyor.Geziyor {
+		return geziyor.NewGeziyor(ctx, &geziyor.Options{
+			StartURLs: []string{ts.URL + "/start"},
+			ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+				jobDirMut.Lock()
+				jobDirVisited = append(jobDirVisited, r.Request.URL.Path)
+				jobDirMut.Unlock()
+				g.Get(ctx, ts.URL+"/a", jobDirDetail)
+				g.Stop()
+				g.Get(ctx, ts.URL+"/b", jobDirDetail)
+			},
+			Callbacks:          map[string]geziyor.ParseFunc{"detail": jobDirDetail},
+			ConcurrentRequests: 1,
+			JobDir:             jobDir,
+			RobotsTxtDisabled:  true,
+			LogDisabled:        true,
+		})
+	}
+
+	// First run is stopped after the start page
+	newGeziyor().Start(ctx)
+	assert.Equal(t, []string{"/start"}, jobDirVisited)
+
+	// Second run continues with pending requests
+	jobDirVisited = nil
+	newGeziyor().Start(ctx)
+	sort.Strings(jobDirVisited)
+	assert.Equal(t, []string{"/a", "/b"}, jobDirVisited)
+
+	// Nothing left, so crawl starts over. Start URL is already visited.
+	jobDirVisited = nil
+	newGeziyor().Start(ctx)
+	assert.Empty(t, jobDirVisited)
+}
+
+func TestJobDirClosureCallback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	jobDir := t.TempDir()
+	ctx := context.Background()
+
+	var mut sync.Mutex
+	var parsed, detailed []string
+	detail := func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+		mut.Lock()
+		detailed = append(detailed, r.Request.URL.Path)
+		mut.Unlock()
+	}
+	newGeziyor := func() *geziyor.Geziyor {
+		return geziyor.NewGeziyor(ctx, &geziyor.Options{
+			StartURLs: []string{ts.URL + "/start"},
+			ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+				mut.Lock()
+				parsed = append(parsed, r.Request.URL.Path)
+				mut.Unlock()
+				g.Stop()
+				g.Get(ctx, ts.URL+"/a", detail)
+			},
+			Callbacks:         map[string]geziyor.ParseFunc{"detail": detail},
+			JobDir:            jobDir,
+			RobotsTxtDisabled: true,
+			LogDisabled:       true,
+		})
+	}
+	newGeziyor().Start(ctx)
+
+	// Closures can't be told apart, so resumed request falls back to ParseFunc
+	newGeziyor().Start(ctx)
+	assert.Equal(t, []string{"/start", "/a"}, parsed)
+	assert.Empty(t, detailed)
+}
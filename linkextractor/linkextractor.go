@@ -0,0 +1,156 @@
+// Package linkextractor extracts links to follow from HTML responses.
+package linkextractor
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/toqueteos/geziyor/client"
+)
+
+// Link is a link extracted from a response
+type Link struct {
+	// URL is the absolute URL of link, without fragment
+	URL string
+	// Text is the text of link element
+	Text string
+	// NoFollow is true if link has rel="nofollow"
+	NoFollow bool
+}
+
+// LinkExtractor extracts links from Response.HTMLDoc.
+// Links are resolved against Response.BaseURL, only http and https links are extracted,
+// and every URL is extracted once per response.
+type LinkExtractor struct {
+	// Only links matching any of these are extracted. Default: All
+	Allow []*regexp.Regexp
+
+	// Links matching any of these are not extracted, even if they match Allow
+	Deny []*regexp.Regexp
+
+	// Only links to these domains or their subdomains are extracted. Default: All
+	AllowDomains []string
+
+	// Links to these domains or their subdomains are not extracted
+	DenyDomains []string
+
+	// Links are only extracted from inside of elements matching these CSS selectors. Default: Whole document
+	RestrictCSS []string
+
+	// Tags to extract links from, such as "a", "area", "link" or "iframe". Default: "a", "area"
+	Tags []string
+
+	// Attributes of Tags containing URLs, such as "href" or "src". Default: "href"
+	Attrs []string
+
+	// If true, URLs are canonicalized using client.CanonicalizeURL
+	Canonicalize bool
+}
+
+var (
+	defaultTags  = []string{"a", "area"}
+	defaultAttrs = []string{"href"}
+)
+
+// Extract returns links of response in document order.
+// Returns nil if response isn't parsed as HTML.
+func (e *LinkExtractor) Extract(r *client.Response) []Link {
+	if r.HTMLDoc == nil {
+		return nil
+	}
+
+	tags, attrs := e.Tags, e.Attrs
+	if len(tags) == 0 {
+		tags = defaultTags
+	}
+	if len(attrs) == 0 {
+		attrs = defaultAttrs
+	}
+
+	regions := r.HTMLDoc.Selection
+	if len(e.RestrictCSS) != 0 {
+		regions = r.HTMLDoc.Find(strings.Join(e.RestrictCSS, ", "))
+	}
+
+	base := r.BaseURL()
+	seen := make(map[string]bool)
+	var links []Link
+	tagSelector := strings.Join(tags, ", ")
+	elements := regions.Filter(tagSelector).AddSelection(regions.Find(tagSelector))
+	elements.Each(func(_ int, s *goquery.Selection) {
+		for _, attr := range attrs {
+			value, ok := s.Attr(attr)
+			if !ok {
+				continue
+			}
+			u, err := base.Parse(strings.TrimSpace(value))
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				continue
+			}
+			u.Fragment = ""
+			u.RawFragment = ""
+			if e.Canonicalize {
+				u = client.CanonicalizeURL(u, nil, false)
+			}
+			link := u.String()
+			if seen[link] || !e.matches(u, link) {
+				continue
+			}
+			seen[link] = true
+			links = append(links, Link{
+				URL:      link,
+				Text:     strings.TrimSpace(s.Text()),
+				NoFollow: hasToken(s.AttrOr("rel", ""), "nofollow"),
+			})
+		}
+	})
+	return links
+}
+
+// matches reports whether link passes domain and pattern filters
+func (e *LinkExtractor) matches(u *url.URL, link string) bool {
+	host := u.Hostname()
+	if len(e.AllowDomains) != 0 && !matchesDomain(host, e.AllowDomains) {
+		return false
+	}
+	if matchesDomain(host, e.DenyDomains) {
+		return false
+	}
+	if len(e.Allow) != 0 && !matchesAny(link, e.Allow) {
+		return false
+	}
+	return !matchesAny(link, e.Deny)
+}
+
+// matchesDomain reports whether host is one of domains or their subdomains
+func matchesDomain(host string, domains []string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(link string, patterns []*regexp.Regexp) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasToken reports whether space separated list contains token, case insensitively
+func hasToken(list string, token string) bool {
+	for _, field := range strings.Fields(list) {
+		if strings.EqualFold(field, token) {
+			return true
+		}
+	}
+	return false
+}
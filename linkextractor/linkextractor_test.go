@@ -0,0 +1,82 @@
+package linkextractor
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor/client"
+)
+
+const page = `<html><body>
+<div id="nav">
+  <a href="/category/books">Books</a>
+  <a href="/category/books#top">Books again</a>
+  <a href="https://other.com/" rel="external nofollow">Other</a>
+  <a href="mailto:info@example.com">Mail</a>
+</div>
+<div id="content">
+  <a href="product?id=2&amp;color=red">Product</a>
+  <a href="/login">Login</a>
+  <iframe src="/embed"></iframe>
+  <map><area href="/area"></map>
+</div>
+</body></html>`
+
+func newResponse(t *testing.T) *client.Response {
+	req, _ := client.NewRequest(context.Background(), "GET", "https://example.com/shop/", nil)
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	assert.NoError(t, err)
+	return &client.Response{Request: req, HTMLDoc: doc}
+}
+
+func urls(links []Link) []string {
+	var result []string
+	for _, link := range links {
+		result = append(result, link.URL)
+	}
+	return result
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name      string
+		extractor LinkExtractor
+		want      []string
+	}{
+		{"Default", LinkExtractor{}, []string{
+			"https://example.com/category/books",
+			"https://other.com/",
+			"https://example.com/shop/product?id=2&color=red",
+			"https://example.com/login",
+			"https://example.com/area",
+		}},
+		{"AllowDeny", LinkExtractor{Allow: []*regexp.Regexp{regexp.MustCompile(`example\.com`)}, Deny: []*regexp.Regexp{regexp.MustCompile(`/login`)}}, []string{
+			"https://example.com/category/books",
+			"https://example.com/shop/product?id=2&color=red",
+			"https://example.com/area",
+		}},
+		{"DenyDomains", LinkExtractor{DenyDomains: []string{"example.com"}}, []string{"https://other.com/"}},
+		{"RestrictCSS", LinkExtractor{RestrictCSS: []string{"#content"}, Tags: []string{"a", "iframe"}, Attrs: []string{"href", "src"}}, []string{
+			"https://example.com/shop/product?id=2&color=red",
+			"https://example.com/login",
+			"https://example.com/embed",
+		}},
+		{"Canonicalize", LinkExtractor{RestrictCSS: []string{"#content a:first-child"}, Canonicalize: true}, []string{
+			"https://example.com/shop/product?color=red&id=2",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, urls(tt.extractor.Extract(newResponse(t))))
+		})
+	}
+}
+
+func TestExtractLink(t *testing.T) {
+	links := (&LinkExtractor{AllowDomains: []string{"other.com"}}).Extract(newResponse(t))
+	assert.Equal(t, []Link{{URL: "https://other.com/", Text: "Other", NoFollow: true}}, links)
+}
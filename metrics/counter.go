@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// CounterValue is the total value of a counter with its label values.
+type CounterValue struct {
+	Name        string   `json:"name"`
+	LabelValues []string `json:"label_values,omitempty"`
+	Value       float64  `json:"value"`
+}
+
+// counterValues keeps totals of all counters, so they can be saved and restored later.
+type counterValues struct {
+	mut    sync.Mutex
+	values map[string]*CounterValue
+}
+
+func (v *counterValues) add(name string, labelValues []string, delta float64) {
+	key := name + "\x00" + strings.Join(labelValues, "\x00")
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	if v.values == nil {
+		v.values = make(map[string]*CounterValue)
+	}
+	value, exists := v.values[key]
+	if !exists {
+		value = &CounterValue{Name: name, LabelValues: labelValues}
+		v.values[key] = value
+	}
+	value.Value += delta
+}
+
+func (v *counterValues) list() []CounterValue {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	values := make([]CounterValue, 0, len(v.values))
+	for _, value := range v.values {
+		values = append(values, *value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Name != values[j].Name {
+			return values[i].Name < values[j].Name
+		}
+		return strings.Join(values[i].LabelValues, ",") < strings.Join(values[j].LabelValues, ",")
+	})
+	return values
+}
+
+// counter wraps metrics.Counter to keep track of added values
+type counter struct {
+	metrics.Counter
+	name        string
+	labelValues []string
+	values      *counterValues
+}
+
+func newCounter(name string, c metrics.Counter, values *counterValues) *counter {
+	return &counter{Counter: c, name: name, values: values}
+}
+
+func (c *counter) With(labelValues ...string) metrics.Counter {
+	return &counter{
+		Counter:     c.Counter.With(labelValues...),
+		name:        c.name,
+		labelValues: append(c.labelValues[:len(c.labelValues):len(c.labelValues)], labelValues...),
+		values:      c.values,
+	}
+}
+
+func (c *counter) Add(delta float64) {
+	c.Counter.Add(delta)
+	c.values.add(c.name, c.labelValues, delta)
+}
@@ -26,34 +26,98 @@ const (
 
 // Metrics type stores metrics
 type Metrics struct {
-	RequestCounter            metrics.Counter
-	ResponseCounter           metrics.Counter
-	PanicCounter              metrics.Counter
-	RobotsTxtRequestCounter   metrics.Counter
-	RobotsTxtResponseCounter  metrics.Counter
-	RobotsTxtForbiddenCounter metrics.Counter
+	RequestCounter               metrics.Counter
+	RequestDepthCounter          metrics.Counter
+	ResponseCounter              metrics.Counter
+	PanicCounter                 metrics.Counter
+	RobotsTxtRequestCounter      metrics.Counter
+	RobotsTxtResponseCounter     metrics.Counter
+	RobotsTxtForbiddenCounter    metrics.Counter
+	ItemDroppedCounter           metrics.Counter
+	RetryCounter                 metrics.Counter
+	DupeFilterSizeGauge          metrics.Gauge
+	AutoThrottleDelayGauge       metrics.Gauge
+	AutoThrottleConcurrencyGauge metrics.Gauge
+
+	values   *counterValues
+	counters map[string]metrics.Counter
 }
 
 // NewMetrics creates new metrics with given metrics.Type
 func NewMetrics(metricsType Type) *Metrics {
+	m := newMetrics(metricsType)
+	if m == nil {
+		return nil
+	}
+
+	// Keep track of counter values, so they can be restored on next runs
+	m.values = &counterValues{}
+	m.counters = make(map[string]metrics.Counter)
+	m.RequestCounter = m.track("request_count", m.RequestCounter)
+	m.RequestDepthCounter = m.track("request_depth_count", m.RequestDepthCounter)
+	m.ResponseCounter = m.track("response_count", m.ResponseCounter)
+	m.PanicCounter = m.track("panic_count", m.PanicCounter)
+	m.RobotsTxtRequestCounter = m.track("robotstxt_request_count", m.RobotsTxtRequestCounter)
+	m.RobotsTxtResponseCounter = m.track("robotstxt_response_count", m.RobotsTxtResponseCounter)
+	m.RobotsTxtForbiddenCounter = m.track("robotstxt_forbidden_count", m.RobotsTxtForbiddenCounter)
+	m.ItemDroppedCounter = m.track("item_dropped_count", m.ItemDroppedCounter)
+	m.RetryCounter = m.track("retry_count", m.RetryCounter)
+	return m
+}
+
+// track wraps counter to keep track of its values
+func (m *Metrics) track(name string, c metrics.Counter) metrics.Counter {
+	tracked := newCounter(name, c, m.values)
+	m.counters[name] = tracked
+	return tracked
+}
+
+// CounterValues returns totals of all counters since they're created or restored
+func (m *Metrics) CounterValues() []CounterValue {
+	return m.values.list()
+}
+
+// RestoreCounterValues adds previously saved counter totals to counters.
+// Values of unknown counters are ignored.
+func (m *Metrics) RestoreCounterValues(values []CounterValue) {
+	for _, value := range values {
+		if c, exists := m.counters[value.Name]; exists {
+			c.With(value.LabelValues...).Add(value.Value)
+		}
+	}
+}
+
+func newMetrics(metricsType Type) *Metrics {
 	switch metricsType {
 	case Discard:
 		return &Metrics{
-			RequestCounter:            discard.NewCounter(),
-			ResponseCounter:           discard.NewCounter(),
-			PanicCounter:              discard.NewCounter(),
-			RobotsTxtRequestCounter:   discard.NewCounter(),
-			RobotsTxtResponseCounter:  discard.NewCounter(),
-			RobotsTxtForbiddenCounter: discard.NewCounter(),
+			RequestCounter:               discard.NewCounter(),
+			RequestDepthCounter:          discard.NewCounter(),
+			ResponseCounter:              discard.NewCounter(),
+			PanicCounter:                 discard.NewCounter(),
+			RobotsTxtRequestCounter:      discard.NewCounter(),
+			RobotsTxtResponseCounter:     discard.NewCounter(),
+			RobotsTxtForbiddenCounter:    discard.NewCounter(),
+			ItemDroppedCounter:           discard.NewCounter(),
+			RetryCounter:                 discard.NewCounter(),
+			DupeFilterSizeGauge:          discard.NewGauge(),
+			AutoThrottleDelayGauge:       discard.NewGauge(),
+			AutoThrottleConcurrencyGauge: discard.NewGauge(),
 		}
 	case ExpVar:
 		return &Metrics{
-			RequestCounter:            expvar.NewCounter("request_count"),
-			ResponseCounter:           expvar.NewCounter("response_count"),
-			PanicCounter:              expvar.NewCounter("panic_count"),
-			RobotsTxtRequestCounter:   expvar.NewCounter("robotstxt_request_count"),
-			RobotsTxtResponseCounter:  expvar.NewCounter("robotstxt_response_count"),
-			RobotsTxtForbiddenCounter: expvar.NewCounter("robotstxt_forbidden_count"),
+			RequestCounter:               expvar.NewCounter("request_count"),
+			RequestDepthCounter:          expvar.NewCounter("request_depth_count"),
+			ResponseCounter:              expvar.NewCounter("response_count"),
+			PanicCounter:                 expvar.NewCounter("panic_count"),
+			RobotsTxtRequestCounter:      expvar.NewCounter("robotstxt_request_count"),
+			RobotsTxtResponseCounter:     expvar.NewCounter("robotstxt_response_count"),
+			RobotsTxtForbiddenCounter:    expvar.NewCounter("robotstxt_forbidden_count"),
+			ItemDroppedCounter:           expvar.NewCounter("item_dropped_count"),
+			RetryCounter:                 expvar.NewCounter("retry_count"),
+			DupeFilterSizeGauge:          expvar.NewGauge("dupefilter_size"),
+			AutoThrottleDelayGauge:       expvar.NewGauge("autothrottle_delay_seconds"),
+			AutoThrottleConcurrencyGauge: expvar.NewGauge("autothrottle_concurrency"),
 		}
 	case Prometheus:
 		return &Metrics{
@@ -62,6 +126,11 @@ func NewMetrics(metricsType Type) *Metrics {
 				Name:      "request_count",
 				Help:      "Request count",
 			}, []string{"method"}),
+			RequestDepthCounter: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "geziyor",
+				Name:      "request_depth_count",
+				Help:      "Request count per depth",
+			}, []string{"depth"}),
 			ResponseCounter: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
 				Namespace: "geziyor",
 				Name:      "response_count",
@@ -87,6 +156,31 @@ func NewMetrics(metricsType Type) *Metrics {
 				Name:      "robotstxt_forbidden_count",
 				Help:      "Robotstxt forbidden count",
 			}, []string{"method"}),
+			ItemDroppedCounter: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "geziyor",
+				Name:      "item_dropped_count",
+				Help:      "Item dropped by pipelines count",
+			}, []string{"pipeline"}),
+			RetryCounter: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "geziyor",
+				Name:      "retry_count",
+				Help:      "Retry count",
+			}, []string{"reason"}),
+			DupeFilterSizeGauge: prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+				Namespace: "geziyor",
+				Name:      "dupefilter_size",
+				Help:      "Number of visited request fingerprints in dupefilter",
+			}, []string{}),
+			AutoThrottleDelayGauge: prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+				Namespace: "geziyor",
+				Name:      "autothrottle_delay_seconds",
+				Help:      "Current AutoThrottle delay per host",
+			}, []string{"host"}),
+			AutoThrottleConcurrencyGauge: prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+				Namespace: "geziyor",
+				Name:      "autothrottle_concurrency",
+				Help:      "Current number of requests in progress per host",
+			}, []string{"host"}),
 		}
 	default:
 		return nil
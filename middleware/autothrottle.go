@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/metrics"
+	"github.com/toqueteos/geziyor/signals"
+)
+
+// Default values for AutoThrottle
+const (
+	DefaultAutoThrottleStartDelay        = 5 * time.Second
+	DefaultAutoThrottleMaxDelay          = 60 * time.Second
+	DefaultAutoThrottleTargetConcurrency = 1.0
+)
+
+// AutoThrottle adjusts delays between requests to the same host by their download latency.
+// Delay of a host moves towards latency / TargetConcurrency,
+// so that on average TargetConcurrency requests are processed by the host in parallel.
+// Responses with 429 and 503 status codes and timeouts double the delay.
+// Other non-200 responses can't decrease the delay, as error pages are usually faster.
+//
+// AutoThrottle only computes delays, Geziyor waits Delay of a host between its requests without holding workers.
+type AutoThrottle struct {
+	// Initial delay of hosts. Default: DefaultAutoThrottleStartDelay
+	StartDelay time.Duration
+
+	// Minimum delay of hosts. Default: 0
+	MinDelay time.Duration
+
+	// Maximum delay of hosts. Default: DefaultAutoThrottleMaxDelay
+	MaxDelay time.Duration
+
+	// Average number of requests to process in parallel per host. Default: DefaultAutoThrottleTargetConcurrency
+	TargetConcurrency float64
+
+	// Metrics to report delays and concurrency of hosts. Optional
+	Metrics *metrics.Metrics
+
+	initOnce sync.Once
+	mut      sync.Mutex
+	hosts    map[string]*throttledHost
+}
+
+// throttledHost is the throttling state of a host
+type throttledHost struct {
+	delay       time.Duration
+	minDelay    time.Duration
+	concurrency int
+}
+
+// Connect subscribes to requests that are about to be made, their responses and errors.
+// Every attempt of a request reaches downloader, and ends with a response, a retry or an error.
+func (a *AutoThrottle) Connect(bus *signals.Bus) {
+	bus.Connect(signals.RequestReachedDownloader, func(e *signals.Event) {
+		a.start(e.Request)
+	})
+	bus.Connect(signals.ResponseReceived, func(e *signals.Event) {
+		a.done(e.Request, e.Response, nil)
+	})
+	bus.Connect(signals.RequestRetried, func(e *signals.Event) {
+		a.done(e.Request, nil, e.Err)
+	})
+	bus.Connect(signals.ErrorRaised, func(e *signals.Event) {
+		// Panics of callbacks are also sent as errors, only request errors are of type *client.Error
+		var reqErr *client.Error
+		if errors.As(e.Err, &reqErr) {
+			a.done(e.Request, nil, e.Err)
+		}
+	})
+}
+
+// Delay returns current delay of host
+func (a *AutoThrottle) Delay(host string) time.Duration {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	return a.host(host).delay
+}
+
+// SetMinDelay sets minimum delay of host, e.g. from crawl delay of its robots.txt.
+// It overrides MinDelay if it's longer, but it's still capped by MaxDelay.
+func (a *AutoThrottle) SetMinDelay(host string, delay time.Duration) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	h := a.host(host)
+	h.minDelay = delay
+	h.delay = a.clamp(h, h.delay)
+	a.report(host, h)
+}
+
+// host returns throttling state of host. Must be called with lock held.
+func (a *AutoThrottle) host(host string) *throttledHost {
+	a.initOnce.Do(func() {
+		if a.StartDelay == 0 {
+			a.StartDelay = DefaultAutoThrottleStartDelay
+		}
+		if a.MaxDelay == 0 {
+			a.MaxDelay = DefaultAutoThrottleMaxDelay
+		}
+		if a.TargetConcurrency <= 0 {
+			a.TargetConcurrency = DefaultAutoThrottleTargetConcurrency
+		}
+		a.hosts = make(map[string]*throttledHost)
+	})
+	h, exists := a.hosts[host]
+	if !exists {
+		h = &throttledHost{}
+		h.delay = a.clamp(h, a.StartDelay)
+		a.hosts[host] = h
+	}
+	return h
+}
+
+// start counts request as being processed by its host
+func (a *AutoThrottle) start(req *client.Request) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	h := a.host(req.Host)
+	h.concurrency++
+	a.report(req.Host, h)
+}
+
+// done adjusts delay of request's host by response latency or error
+func (a *AutoThrottle) done(req *client.Request, res *client.Response, err error) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	h := a.host(req.Host)
+	h.concurrency--
+	defer a.report(req.Host, h)
+
+	var reqErr *client.Error
+	if errors.As(err, &reqErr) {
+		switch {
+		case reqErr.Kind == client.KindTimeout:
+			a.backoff(h)
+			return
+		case reqErr.Response != nil:
+			res = reqErr.Response
+		default:
+			return
+		}
+	}
+	if res == nil {
+		return
+	}
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable {
+		a.backoff(h)
+		return
+	}
+
+	targetDelay := time.Duration(float64(res.Latency) / a.TargetConcurrency)
+	newDelay := (h.delay + targetDelay) / 2
+	if newDelay < targetDelay {
+		newDelay = targetDelay
+	}
+	newDelay = a.clamp(h, newDelay)
+	if res.StatusCode != http.StatusOK && newDelay <= h.delay {
+		return
+	}
+	h.delay = newDelay
+}
+
+// backoff doubles delay of host, at least to StartDelay
+func (a *AutoThrottle) backoff(h *throttledHost) {
+	delay := h.delay * 2
+	if delay < a.StartDelay {
+		delay = a.StartDelay
+	}
+	h.delay = a.clamp(h, delay)
+}
+
+// clamp limits delay of host between minimum and maximum delays
+func (a *AutoThrottle) clamp(h *throttledHost, delay time.Duration) time.Duration {
+	minDelay := a.MinDelay
+	if h.minDelay > minDelay {
+		minDelay = h.minDelay
+	}
+	if delay < minDelay {
+		delay = minDelay
+	}
+	if delay > a.MaxDelay {
+		return a.MaxDelay
+	}
+	return delay
+}
+
+// report sets metrics of host. Must be called with lock held.
+func (a *AutoThrottle) report(host string, h *throttledHost) {
+	if a.Metrics == nil {
+		return
+	}
+	a.Metrics.AutoThrottleDelayGauge.With("host", host).Set(h.delay.Seconds())
+	a.Metrics.AutoThrottleConcurrencyGauge.With("host", host).Set(float64(h.concurrency))
+}
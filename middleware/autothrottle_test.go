@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/signals"
+)
+
+func TestAutoThrottle(t *testing.T) {
+	throttle := &AutoThrottle{
+		StartDelay:        time.Millisecond,
+		MinDelay:          time.Millisecond,
+		MaxDelay:          time.Second,
+		TargetConcurrency: 2,
+	}
+	bus := signals.NewBus()
+	throttle.Connect(bus)
+
+	req, _ := client.NewRequest(context.Background(), "GET", "https://example.com", nil)
+	respond := func(status int, latency time.Duration) {
+		bus.Send(&signals.Event{Signal: signals.RequestReachedDownloader, Request: req})
+		res := &client.Response{Response: &http.Response{StatusCode: status}, Request: req, Latency: latency}
+		bus.Send(&signals.Event{Signal: signals.ResponseReceived, Request: req, Response: res})
+	}
+
+	// Moves towards latency / TargetConcurrency
+	respond(http.StatusOK, 400*time.Millisecond)
+	assert.Equal(t, 200*time.Millisecond, throttle.Delay("example.com"))
+	respond(http.StatusOK, 100*time.Millisecond)
+	assert.Equal(t, 125*time.Millisecond, throttle.Delay("example.com"))
+
+	// Non-200 responses can't decrease delay
+	respond(http.StatusNotFound, 0)
+	assert.Equal(t, 125*time.Millisecond, throttle.Delay("example.com"))
+
+	// Backs off on 429 and timeouts, up to MaxDelay
+	respond(http.StatusTooManyRequests, 0)
+	assert.Equal(t, 250*time.Millisecond, throttle.Delay("example.com"))
+	bus.Send(&signals.Event{Signal: signals.RequestReachedDownloader, Request: req})
+	bus.Send(&signals.Event{Signal: signals.RequestRetried, Request: req, Err: &client.Error{Kind: client.KindTimeout, Request: req}})
+	assert.Equal(t, 500*time.Millisecond, throttle.Delay("example.com"))
+	bus.Send(&signals.Event{Signal: signals.RequestReachedDownloader, Request: req})
+	bus.Send(&signals.Event{Signal: signals.ErrorRaised, Request: req, Err: &client.Error{Kind: client.KindTimeout, Request: req}})
+	assert.Equal(t, time.Second, throttle.Delay("example.com"))
+
+	// Other hosts aren't affected
+	assert.Equal(t, time.Millisecond, throttle.Delay("other.com"))
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/toqueteos/geziyor/client"
+)
+
+// DepthLimit cancels requests deeper than Limit. See client.Request.Depth
+type DepthLimit struct {
+	Limit int
+}
+
+func (a *DepthLimit) ProcessRequest(r *client.Request) {
+	if a.Limit > 0 && r.Depth() > a.Limit {
+		r.Cancel()
+	}
+}
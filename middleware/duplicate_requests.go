@@ -4,23 +4,48 @@ import (
 	"sync"
 
 	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/dupefilter"
 	"github.com/toqueteos/geziyor/internal"
+	"github.com/toqueteos/geziyor/metrics"
 )
 
-// DuplicateRequests checks for already visited URLs
+// DuplicateRequests checks for already visited requests
 type DuplicateRequests struct {
 	RevisitEnabled bool
-	visitedURLs    sync.Map
-	logOnlyOnce    sync.Map
+
+	// Fingerprinter identifies same requests. Default: client.DefaultFingerprinter
+	Fingerprinter client.Fingerprinter
+
+	// Filter stores fingerprints of visited requests. Default: in-memory dupefilter.Memory
+	Filter dupefilter.DupeFilter
+
+	// Metrics to report size of Filter. Optional
+	Metrics *metrics.Metrics
+
+	initOnce sync.Once
+	logOnce  sync.Once
 }
 
 func (a *DuplicateRequests) ProcessRequest(r *client.Request) {
-	if !a.RevisitEnabled && r.Request.Method == "GET" {
-		requestURL := r.Request.URL.String()
-		if _, visited := a.visitedURLs.LoadOrStore(requestURL, struct{}{}); visited {
-			if _, logged := a.logOnlyOnce.LoadOrStore(requestURL, struct{}{}); !logged {
-				internal.Logger.Printf("URL already visited %s\n", requestURL)
-			}
+	a.initOnce.Do(func() {
+		if a.Fingerprinter == nil {
+			a.Fingerprinter = &client.DefaultFingerprinter{}
+		}
+		if a.Filter == nil {
+			a.Filter = dupefilter.NewMemory()
+		}
+	})
+
+	if !a.RevisitEnabled && !r.DontFilter {
+		visited := a.Filter.Visit(a.Fingerprinter.Fingerprint(r.Request))
+		if a.Metrics != nil {
+			a.Metrics.DupeFilterSizeGauge.Set(float64(a.Filter.Len()))
+		}
+		if visited {
+			// Logging every duplicate would require keeping another set of URLs
+			a.logOnce.Do(func() {
+				internal.Logger.Printf("URL already visited %s (no more duplicates will be logged)\n", r.Request.URL.String())
+			})
 			r.Cancel()
 		}
 	}
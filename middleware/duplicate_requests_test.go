@@ -22,4 +22,24 @@ func TestDuplicateRequests_ProcessRequest(t *testing.T) {
 	duplicateRequestsProcessor.ProcessRequest(req2)
 	duplicateRequestsProcessor.ProcessRequest(req2)
 	duplicateRequestsProcessor.ProcessRequest(req2)
+
+	assert.False(t, req.Cancelled)
+	assert.True(t, req2.Cancelled)
+}
+
+func TestDuplicateRequests_Canonical(t *testing.T) {
+	ctx := context.Background()
+	duplicateRequestsProcessor := DuplicateRequests{}
+	process := func(method, url, body string) bool {
+		req, err := client.NewRequest(ctx, method, url, strings.NewReader(body))
+		assert.NoError(t, err)
+		duplicateRequestsProcessor.ProcessRequest(req)
+		return req.Cancelled
+	}
+
+	assert.False(t, process("GET", "https://example.com/?a=1&b=2", ""))
+	assert.True(t, process("GET", "https://example.com:443/?b=2&a=1&utm_source=feed#top", ""))
+	assert.False(t, process("POST", "https://example.com/search", "q=1"))
+	assert.True(t, process("POST", "https://example.com/search", "q=1"))
+	assert.False(t, process("POST", "https://example.com/search", "q=2"))
 }
@@ -1,8 +1,8 @@
 package middleware
 
 import (
-	"github.com/toqueteos/geziyor/client"
 	"github.com/toqueteos/geziyor/internal"
+	"github.com/toqueteos/geziyor/signals"
 )
 
 // LogStats logs responses
@@ -10,9 +10,13 @@ type LogStats struct {
 	LogDisabled bool
 }
 
-func (p *LogStats) ProcessResponse(r *client.Response) {
+// Connect subscribes to received responses
+func (p *LogStats) Connect(bus *signals.Bus) {
 	// LogDisabled check is not necessary, but done here for performance reasons
-	if !p.LogDisabled {
-		internal.Logger.Printf("Crawled: (%d) <%s %s>", r.StatusCode, r.Request.Method, r.Request.URL.String())
+	if p.LogDisabled {
+		return
 	}
+	bus.Connect(signals.ResponseReceived, func(e *signals.Event) {
+		internal.Logger.Printf("Crawled: (%d) <%s %s>", e.Response.StatusCode, e.Request.Method, e.Request.URL.String())
+	})
 }
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/internal"
+)
+
+// MetaRobots parses X-Robots-Tag headers and <meta name="robots"> tags of responses into Response.Robots.
+// Meta tags and headers for a specific user agent, like <meta name="googlebot">, are used if the name is the product token of UserAgent.
+// See client.MatchRobotsAgent
+// HTML responses must be parsed before, see ParseHTML.
+// If NoFollow is set, requests created from nofollow responses are cancelled.
+type MetaRobots struct {
+	// UserAgent to match user agent specific directives. Default: User-Agent of requests
+	UserAgent string
+
+	// If true, requests originated from responses with nofollow directive are cancelled
+	NoFollow bool
+}
+
+func (m *MetaRobots) ProcessRequest(r *client.Request) {
+	if m.NoFollow && r.OriginRobots().NoFollow {
+		internal.Logger.Println("Forbidden by nofollow:", r.URL.String())
+		r.Cancel()
+	}
+}
+
+func (m *MetaRobots) ProcessResponse(r *client.Response) {
+	agent := m.UserAgent
+	if agent == "" {
+		agent = r.Request.UserAgent()
+	}
+
+	for _, value := range r.Header.Values("X-Robots-Tag") {
+		r.Robots.Parse(value, agent)
+	}
+
+	if r.HTMLDoc == nil {
+		return
+	}
+	r.HTMLDoc.Find("meta[name][content]").Each(func(_ int, s *goquery.Selection) {
+		name := strings.ToLower(strings.TrimSpace(s.AttrOr("name", "")))
+		if name == "robots" || client.MatchRobotsAgent(name, agent) {
+			r.Robots.Parse(s.AttrOr("content", ""), agent)
+		}
+	})
+}
@@ -3,8 +3,8 @@ package middleware
 import (
 	"strconv"
 
-	"github.com/toqueteos/geziyor/client"
 	"github.com/toqueteos/geziyor/metrics"
+	"github.com/toqueteos/geziyor/signals"
 )
 
 // Metrics sets stats for request and responses
@@ -12,10 +12,13 @@ type Metrics struct {
 	Metrics *metrics.Metrics
 }
 
-func (a *Metrics) ProcessRequest(r *client.Request) {
-	a.Metrics.RequestCounter.With("method", r.Method).Add(1)
-}
-
-func (a *Metrics) ProcessResponse(r *client.Response) {
-	a.Metrics.ResponseCounter.With("status", strconv.Itoa(r.StatusCode)).Add(1)
+// Connect subscribes to requests that are about to be made and to received responses
+func (a *Metrics) Connect(bus *signals.Bus) {
+	bus.Connect(signals.RequestReachedDownloader, func(e *signals.Event) {
+		a.Metrics.RequestCounter.With("method", e.Request.Method).Add(1)
+		a.Metrics.RequestDepthCounter.With("depth", strconv.Itoa(e.Request.Depth())).Add(1)
+	})
+	bus.Connect(signals.ResponseReceived, func(e *signals.Event) {
+		a.Metrics.ResponseCounter.With("status", strconv.Itoa(e.Response.StatusCode)).Add(1)
+	})
 }
@@ -8,13 +8,13 @@ import (
 	"github.com/toqueteos/geziyor/internal"
 )
 
-// ParseHTML parses response if response is HTML
+// ParseHTML parses response if response is HTML. Streamed and saved bodies aren't parsed.
 type ParseHTML struct {
 	ParseHTMLDisabled bool
 }
 
 func (p *ParseHTML) ProcessResponse(r *client.Response) {
-	if !p.ParseHTMLDisabled && r.IsHTML() {
+	if !p.ParseHTMLDisabled && r.IsHTML() && r.BodyStream == nil && r.SavedTo == "" {
 		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Body))
 		if err != nil {
 			internal.Logger.Println(err.Error())
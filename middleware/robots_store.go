@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/toqueteos/geziyor/cache"
+)
+
+// RobotsRecord is a fetched robots.txt file.
+// StatusCode is 0 if robots.txt couldn't be fetched because of a network error.
+type RobotsRecord struct {
+	StatusCode int       `json:"status_code"`
+	Body       []byte    `json:"body,omitempty"`
+	FetchedAt  time.Time `json:"fetched_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// RobotsStore stores fetched robots.txt files by their URL, so they can be used across runs.
+// Implementations must be safe for concurrent use.
+type RobotsStore interface {
+	// Get returns the record of robots.txt URL, if it's stored
+	Get(robotsURL string) (*RobotsRecord, bool)
+	// Set stores the record of robots.txt URL
+	Set(robotsURL string, record *RobotsRecord)
+}
+
+// MemoryRobotsStore is an in-memory RobotsStore
+type MemoryRobotsStore struct {
+	mut     sync.RWMutex
+	records map[string]*RobotsRecord
+}
+
+// NewMemoryRobotsStore creates a new in-memory robots.txt store
+func NewMemoryRobotsStore() *MemoryRobotsStore {
+	return &MemoryRobotsStore{records: make(map[string]*RobotsRecord)}
+}
+
+func (s *MemoryRobotsStore) Get(robotsURL string) (*RobotsRecord, bool) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	record, exists := s.records[robotsURL]
+	return record, exists
+}
+
+func (s *MemoryRobotsStore) Set(robotsURL string, record *RobotsRecord) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.records[robotsURL] = record
+}
+
+// CacheRobotsStore stores robots.txt files in a cache backend, such as diskcache or leveldbcache.
+type CacheRobotsStore struct {
+	Cache cache.Cache
+}
+
+// NewCacheRobotsStore creates a robots.txt store using c
+func NewCacheRobotsStore(c cache.Cache) *CacheRobotsStore {
+	return &CacheRobotsStore{Cache: c}
+}
+
+func (s *CacheRobotsStore) Get(robotsURL string) (*RobotsRecord, bool) {
+	data, ok := s.Cache.Get(s.key(robotsURL))
+	if !ok {
+		return nil, false
+	}
+	var record RobotsRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, false
+	}
+	return &record, true
+}
+
+func (s *CacheRobotsStore) Set(robotsURL string, record *RobotsRecord) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	s.Cache.Set(s.key(robotsURL), data)
+}
+
+// key separates robots.txt records from cached responses
+func (s *CacheRobotsStore) key(robotsURL string) string {
+	return "robotstxt:" + robotsURL
+}
@@ -1,33 +1,103 @@
 package middleware
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
+	"net/http"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/temoto/robotstxt"
 	"github.com/toqueteos/geziyor/client"
 	"github.com/toqueteos/geziyor/internal"
 	"github.com/toqueteos/geziyor/metrics"
+	"github.com/toqueteos/geziyor/stats"
 )
 
-// RobotsTxt middleware filters out requests forbidden by the robots.txt exclusion standard.
+// Default values for RobotsTxt
+const (
+	DefaultMaxCrawlDelay  = 60 * time.Second
+	DefaultRobotsTTL      = 24 * time.Hour
+	DefaultRobotsErrorTTL = 10 * time.Minute
+)
+
+// HostThrottle limits request rate of hosts. Geziyor implements it to delay requests without holding workers.
+type HostThrottle interface {
+	SetMinDelay(host string, delay time.Duration)
+}
+
+// RobotsTxt middleware filters out requests forbidden by the robots.txt exclusion standard. (RFC 9309)
+// Crawl-delay and Request-rate of the matching group are applied as minimum delays between requests to hosts.
+// robots.txt of a host is fetched once, concurrent requests to the host wait for it.
+// If robots.txt can't be fetched because of server or network errors, the host is disallowed for ErrorTTL.
 type RobotsTxt struct {
+	// If true, Crawl-delay and Request-rate are ignored
+	CrawlDelayDisabled bool
+
+	// Crawl delays longer than this are capped to it. Default: DefaultMaxCrawlDelay
+	MaxCrawlDelay time.Duration
+
+	// If true, crawl delays longer than MaxCrawlDelay are ignored instead of capped
+	IgnoreExcessiveCrawlDelay bool
+
+	// Throttle receives crawl delays of hosts and delays their requests. Geziyor sets it.
+	// If nil, RobotsTxt delays requests itself by sleeping.
+	Throttle HostThrottle
+
+	// Stats to report effective crawl delays. Optional
+	Stats *stats.Stats
+
+	// How long fetched robots.txt files are used before they're fetched again.
+	// Expired files are still used by other requests while they're refreshed.
+	// Default: DefaultRobotsTTL
+	TTL time.Duration
+
+	// How long server and network errors of robots.txt fetches are cached. Default: DefaultRobotsErrorTTL
+	ErrorTTL time.Duration
+
+	// Store keeps fetched robots.txt files, so they can be used across runs. Optional
+	Store RobotsStore
+
+	// UserAgent is sent with robots.txt requests and matched against user agent groups.
+	// Default: User-Agent of requests
+	UserAgent string
+
 	ctx            context.Context
 	metrics        *metrics.Metrics
 	robotsDisabled bool
 	client         *client.Client
-	mut            sync.RWMutex
-	robotsMap      map[string]*robotstxt.RobotsData
+	mut            sync.Mutex
+	robotsMap      map[string]*robotsEntry
+	fetches        map[string]chan struct{}
+	delays         map[string]*hostDelay
 }
 
-func NewRobotsTxt(ctx context.Context, client *client.Client, metrics *metrics.Metrics, robotsDisabled bool) RequestProcessor {
+// robotsEntry is the parsed robots.txt of a host
+type robotsEntry struct {
+	data         *robotstxt.RobotsData
+	requestRates map[string]time.Duration
+	expiresAt    time.Time
+}
+
+// hostDelay is the crawl delay state of a host
+type hostDelay struct {
+	delay time.Duration
+	next  time.Time
+}
+
+func NewRobotsTxt(ctx context.Context, client *client.Client, metrics *metrics.Metrics, robotsDisabled bool) *RobotsTxt {
 	return &RobotsTxt{
 		ctx:            ctx,
 		metrics:        metrics,
 		robotsDisabled: robotsDisabled,
 		client:         client,
-		robotsMap:      make(map[string]*robotstxt.RobotsData),
+		robotsMap:      make(map[string]*robotsEntry),
+		fetches:        make(map[string]chan struct{}),
+		delays:         make(map[string]*hostDelay),
 	}
 }
 
@@ -36,37 +106,298 @@ func (m *RobotsTxt) ProcessRequest(r *client.Request) {
 		return
 	}
 
-	// TODO: Locking like this improves performance but sometimes it causes duplicate requests to robots.txt
-	m.mut.RLock()
-	robotsData, exists := m.robotsMap[r.Host]
-	m.mut.RUnlock()
+	agent := m.UserAgent
+	if agent == "" {
+		agent = r.UserAgent()
+	}
+	robots := m.robots(r, agent)
+	if robots == nil {
+		return // Request is cancelled while waiting robots.txt
+	}
 
-	if !exists {
-		robotsReq, err := client.NewRequest(m.ctx, "GET", r.URL.Scheme+"://"+r.Host+"/robots.txt", nil)
-		if err != nil {
-			return // Don't Do anything
+	if !robots.data.TestAgent(r.URL.Path, agent) {
+		m.metrics.RobotsTxtForbiddenCounter.With("method", r.Method).Add(1)
+		internal.Logger.Println("Forbidden by robots.txt:", r.URL.String())
+		r.Cancel()
+		return
+	}
+
+	if !m.CrawlDelayDisabled {
+		m.delay(r, m.crawlDelay(robots, agent))
+	}
+}
+
+// Sitemaps returns sitemap URLs listed in robots.txt of r's host, fetching robots.txt if it's not fetched yet
+func (m *RobotsTxt) Sitemaps(r *client.Request) []string {
+	agent := m.UserAgent
+	if agent == "" {
+		agent = r.UserAgent()
+	}
+	robots := m.robots(r, agent)
+	if robots == nil {
+		return nil
+	}
+	return robots.data.Sitemaps
+}
+
+// robots returns robots.txt of request's host.
+// Only one request fetches it, others wait for it or use the expired one if there is.
+func (m *RobotsTxt) robots(r *client.Request, agent string) *robotsEntry {
+	robotsURL := r.URL.Scheme + "://" + r.Host + "/robots.txt"
+	for {
+		m.mut.Lock()
+		robots := m.robotsMap[robotsURL]
+		if robots != nil && time.Now().Before(robots.expiresAt) {
+			m.mut.Unlock()
+			return robots
+		}
+		if done, fetching := m.fetches[robotsURL]; fetching {
+			m.mut.Unlock()
+			if robots != nil {
+				return robots
+			}
+			select {
+			case <-done:
+				continue
+			case <-r.Context().Done():
+				return nil
+			}
 		}
+		done := make(chan struct{})
+		m.fetches[robotsURL] = done
+		m.mut.Unlock()
 
+		robots = m.load(robotsURL, agent)
+
+		m.mut.Lock()
+		m.robotsMap[robotsURL] = robots
+		delete(m.fetches, robotsURL)
+		m.mut.Unlock()
+		close(done)
+		return robots
+	}
+}
+
+// load returns robots.txt from Store if it's not expired, or fetches it
+func (m *RobotsTxt) load(robotsURL string, agent string) *robotsEntry {
+	if m.Store != nil {
+		if record, ok := m.Store.Get(robotsURL); ok && time.Now().Before(record.ExpiresAt) {
+			return newRobotsEntry(record)
+		}
+	}
+	record := m.fetch(robotsURL, agent)
+	if m.Store != nil {
+		m.Store.Set(robotsURL, record)
+	}
+	return newRobotsEntry(record)
+}
+
+// fetch requests robots.txt using client
+func (m *RobotsTxt) fetch(robotsURL string, agent string) *RobotsRecord {
+	record := &RobotsRecord{FetchedAt: time.Now()}
+
+	robotsReq, err := client.NewRequest(m.ctx, "GET", robotsURL, nil)
+	if err == nil {
+		robotsReq.Header.Set("User-Agent", agent)
 		m.metrics.RobotsTxtRequestCounter.Add(1)
-		robotsResp, err := m.client.DoRequest(robotsReq)
-		if err != nil {
-			return // Don't Do anything
+		var robotsResp *client.Response
+		robotsResp, err = m.client.DoRequest(robotsReq)
+		// Responses with retried status codes are returned with error
+		var reqErr *client.Error
+		if errors.As(err, &reqErr) && reqErr.Response != nil {
+			robotsResp, err = reqErr.Response, nil
+		}
+		if err == nil {
+			m.metrics.RobotsTxtResponseCounter.With("status", strconv.Itoa(robotsResp.StatusCode)).Add(1)
+			record.StatusCode = robotsResp.StatusCode
+			record.Body = robotsResp.Body
+		}
+	}
+	if err != nil {
+		internal.Logger.Printf("robots.txt fetch error, host is disallowed for now: %v\n", err)
+	}
+
+	ttl := m.TTL
+	if ttl == 0 {
+		ttl = DefaultRobotsTTL
+	}
+	if record.StatusCode == 0 || record.StatusCode >= 500 {
+		ttl = m.ErrorTTL
+		if ttl == 0 {
+			ttl = DefaultRobotsErrorTTL
 		}
-		m.metrics.RobotsTxtResponseCounter.With("status", strconv.Itoa(robotsResp.StatusCode)).Add(1)
+	}
+	record.ExpiresAt = record.FetchedAt.Add(ttl)
+	return record
+}
+
+// newRobotsEntry parses record.
+// As RFC 9309 requires, 4xx responses allow everything, server and network errors disallow everything.
+func newRobotsEntry(record *RobotsRecord) *robotsEntry {
+	statusCode := record.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusServiceUnavailable
+	}
+	data, err := robotstxt.FromStatusAndBytes(statusCode, record.Body)
+	if err != nil {
+		// Unparsable robots.txt allows everything
+		data, _ = robotstxt.FromStatusAndBytes(http.StatusNotFound, nil)
+	}
+	robots := &robotsEntry{data: data, expiresAt: record.ExpiresAt}
+	if statusCode >= 200 && statusCode < 300 {
+		robots.requestRates = parseRequestRates(record.Body)
+	}
+	return robots
+}
+
+// crawlDelay returns the effective crawl delay of agent, after applying MaxCrawlDelay
+func (m *RobotsTxt) crawlDelay(robots *robotsEntry, agent string) time.Duration {
+	var delay time.Duration
+	if group := robots.data.FindGroup(agent); group != nil {
+		delay = group.CrawlDelay
+	}
+	if interval := requestRate(robots.requestRates, agent); interval > delay {
+		delay = interval
+	}
 
-		robotsData, err = robotstxt.FromStatusAndBytes(robotsResp.StatusCode, robotsResp.Body)
-		if err != nil {
-			return // Don't Do anything
+	maxDelay := m.MaxCrawlDelay
+	if maxDelay == 0 {
+		maxDelay = DefaultMaxCrawlDelay
+	}
+	if delay > maxDelay {
+		if m.IgnoreExcessiveCrawlDelay {
+			return 0
 		}
+		return maxDelay
+	}
+	return delay
+}
 
-		m.mut.Lock()
-		m.robotsMap[r.Host] = robotsData
-		m.mut.Unlock()
+// delay applies crawl delay to request's host, either by Throttle or by waiting itself
+func (m *RobotsTxt) delay(r *client.Request, delay time.Duration) {
+	m.mut.Lock()
+	h, exists := m.delays[r.Host]
+	if !exists {
+		h = &hostDelay{}
+		m.delays[r.Host] = h
 	}
+	changed := !exists || h.delay != delay
+	h.delay = delay
 
-	if !robotsData.TestAgent(r.URL.Path, r.UserAgent()) {
-		m.metrics.RobotsTxtForbiddenCounter.With("method", r.Method).Add(1)
-		internal.Logger.Println("Forbidden by robots.txt:", r.URL.String())
-		r.Cancel()
+	var wait time.Duration
+	if m.Throttle == nil && delay > 0 {
+		now := time.Now()
+		if wait = h.next.Sub(now); wait < 0 {
+			wait = 0
+		}
+		h.next = now.Add(wait + delay)
+	}
+	m.mut.Unlock()
+
+	if changed {
+		if m.Stats != nil {
+			m.Stats.RecordCrawlDelay(r.Host, delay)
+		}
+		if m.Throttle != nil {
+			m.Throttle.SetMinDelay(r.Host, delay)
+		}
+	}
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+		}
+	}
+}
+
+// parseRequestRates parses Request-rate lines of robots.txt, which aren't supported by robotstxt package.
+// Returns interval between requests by lowercase user agent.
+func parseRequestRates(body []byte) map[string]time.Duration {
+	rates := make(map[string]time.Duration)
+	var agents []string
+	inRules := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent", "useragent":
+			// Consecutive user agent lines share the same group
+			if inRules {
+				agents = nil
+				inRules = false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "request-rate":
+			inRules = true
+			if interval, ok := parseRequestRate(value); ok {
+				for _, agent := range agents {
+					rates[agent] = interval
+				}
+			}
+		default:
+			inRules = true
+		}
+	}
+	return rates
+}
+
+// parseRequestRate parses Request-rate value "requests/period" to interval between requests.
+// Period is in seconds unless it has s, m or h unit. Time windows after the rate are ignored.
+func parseRequestRate(value string) (time.Duration, bool) {
+	if fields := strings.Fields(value); len(fields) > 0 {
+		value = fields[0]
+	}
+	requestsStr, periodStr, found := strings.Cut(value, "/")
+	if !found {
+		return 0, false
+	}
+	requests, err := strconv.Atoi(requestsStr)
+	if err != nil || requests <= 0 {
+		return 0, false
+	}
+
+	unit := time.Second
+	switch {
+	case strings.HasSuffix(periodStr, "s"):
+		periodStr = strings.TrimSuffix(periodStr, "s")
+	case strings.HasSuffix(periodStr, "m"):
+		periodStr, unit = strings.TrimSuffix(periodStr, "m"), time.Minute
+	case strings.HasSuffix(periodStr, "h"):
+		periodStr, unit = strings.TrimSuffix(periodStr, "h"), time.Hour
+	}
+	period, err := strconv.ParseFloat(periodStr, 64)
+	if err != nil || period <= 0 {
+		return 0, false
+	}
+	return time.Duration(period * float64(unit) / float64(requests)), true
+}
+
+// requestRate returns request rate interval of agent.
+// The longest agent name contained in agent is used, or "*" if there isn't any.
+func requestRate(rates map[string]time.Duration, agent string) time.Duration {
+	agent = strings.ToLower(agent)
+	best := ""
+	for name := range rates {
+		if name != "*" && strings.Contains(agent, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		best = "*"
 	}
+	return rates[best]
 }
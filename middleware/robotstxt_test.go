@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor/cache/memorycache"
+	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/metrics"
+	"github.com/toqueteos/geziyor/stats"
+)
+
+// fakeThrottle records minimum delays of hosts
+type fakeThrottle map[string]time.Duration
+
+func (f fakeThrottle) SetMinDelay(host string, delay time.Duration) {
+	f[host] = delay
+}
+
+func newRobotsServer(robots string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, robots)
+		}
+	}))
+}
+
+func newTestRobotsTxt() *RobotsTxt {
+	return NewRobotsTxt(context.Background(), client.NewClient(&client.Options{MaxBodySize: client.DefaultMaxBody}), metrics.NewMetrics(metrics.Discard), false)
+}
+
+func TestRobotsTxtCrawlDelay(t *testing.T) {
+	ts := newRobotsServer("User-agent: *\nCrawl-delay: 0.1\nDisallow: /private\n")
+	defer ts.Close()
+
+	robots := newTestRobotsTxt()
+	robots.Stats = stats.New()
+
+	start := time.Now()
+	for _, path := range []string{"/1", "/2", "/3"} {
+		req, _ := client.NewRequest(context.Background(), "GET", ts.URL+path, nil)
+		robots.ProcessRequest(req)
+		assert.False(t, req.Cancelled)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	req, _ := client.NewRequest(context.Background(), "GET", ts.URL+"/private", nil)
+	robots.ProcessRequest(req)
+	assert.True(t, req.Cancelled)
+
+	assert.Equal(t, 0.1, robots.Stats.Domains[req.Host].CrawlDelay)
+}
+
+func TestRobotsTxtMaxCrawlDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		robots string
+		ignore bool
+		want   time.Duration
+	}{
+		{"Capped", "User-agent: *\nCrawl-delay: 100\n", false, 10 * time.Second},
+		{"Ignored", "User-agent: *\nCrawl-delay: 100\n", true, 0},
+		{"RequestRate", "User-agent: *\nRequest-rate: 1/5\n", false, 5 * time.Second},
+		{"AgentGroup", "User-agent: geziyor\nRequest-rate: 1/2\n\nUser-agent: *\nCrawl-delay: 1\n", false, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newRobotsServer(tt.robots)
+			defer ts.Close()
+
+			throttle := fakeThrottle{}
+			robots := newTestRobotsTxt()
+			robots.MaxCrawlDelay = 10 * time.Second
+			robots.IgnoreExcessiveCrawlDelay = tt.ignore
+			robots.Throttle = throttle
+
+			req, _ := client.NewRequest(context.Background(), "GET", ts.URL, nil)
+			req.Header.Set("User-Agent", client.DefaultUserAgent)
+			robots.ProcessRequest(req)
+			assert.Equal(t, tt.want, throttle[req.Host])
+		})
+	}
+}
+
+func TestParseRequestRate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"1/5", 5 * time.Second, true},
+		{"1/5s", 5 * time.Second, true},
+		{"6/1m", 10 * time.Second, true},
+		{"1/1h 0600-0845", time.Hour, true},
+		{"0/5", 0, false},
+		{"5", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRequestRate(tt.value)
+		assert.Equal(t, tt.ok, ok, tt.value)
+		assert.Equal(t, tt.want, got, tt.value)
+	}
+}
+
+func TestRobotsTxtSingleFlight(t *testing.T) {
+	var fetches atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fetches.Add(1)
+			assert.Equal(t, "robotbot", r.UserAgent())
+			time.Sleep(50 * time.Millisecond)
+			fmt.Fprint(w, "User-agent: robotbot\nDisallow: /private\n")
+		}
+	}))
+	defer ts.Close()
+
+	robots := newTestRobotsTxt()
+	robots.UserAgent = "robotbot"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := client.NewRequest(context.Background(), "GET", ts.URL+"/private", nil)
+			robots.ProcessRequest(req)
+			assert.True(t, req.Cancelled)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(1), fetches.Load())
+}
+
+func TestRobotsTxtStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		disallowed bool
+	}{
+		{"NotFound", http.StatusNotFound, false},
+		{"ServerError", http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetches atomic.Int64
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/robots.txt" {
+					fetches.Add(1)
+					w.WriteHeader(tt.status)
+				}
+			}))
+			defer ts.Close()
+
+			robots := newTestRobotsTxt()
+			robots.TTL = time.Hour
+			robots.ErrorTTL = 100 * time.Millisecond
+
+			for i := 0; i < 2; i++ {
+				req, _ := client.NewRequest(context.Background(), "GET", ts.URL+"/page", nil)
+				robots.ProcessRequest(req)
+				assert.Equal(t, tt.disallowed, req.Cancelled)
+			}
+			assert.Equal(t, int64(1), fetches.Load())
+
+			// Errors expire after ErrorTTL, successful fetches after TTL
+			time.Sleep(150 * time.Millisecond)
+			req, _ := client.NewRequest(context.Background(), "GET", ts.URL+"/page", nil)
+			robots.ProcessRequest(req)
+			if tt.disallowed {
+				assert.Equal(t, int64(2), fetches.Load())
+			} else {
+				assert.Equal(t, int64(1), fetches.Load())
+			}
+		})
+	}
+}
+
+func TestRobotsTxtStore(t *testing.T) {
+	var fetches atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fetches.Add(1)
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		}
+	}))
+	defer ts.Close()
+
+	store := NewCacheRobotsStore(memorycache.New())
+	for run := 0; run < 2; run++ {
+		robots := newTestRobotsTxt()
+		robots.Store = store
+		req, _ := client.NewRequest(context.Background(), "GET", ts.URL+"/private", nil)
+		robots.ProcessRequest(req)
+		assert.True(t, req.Cancelled)
+	}
+	assert.Equal(t, int64(1), fetches.Load())
+
+	record, ok := store.Get(ts.URL + "/robots.txt")
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusOK, record.StatusCode)
+	assert.Equal(t, "User-agent: *\nDisallow: /private\n", string(record.Body))
+}
@@ -4,26 +4,58 @@ import (
 	"context"
 	"net/http"
 	"net/url"
+	"regexp"
 	"time"
 
 	"github.com/chromedp/chromedp"
 	"github.com/toqueteos/geziyor/cache"
 	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/dupefilter"
 	"github.com/toqueteos/geziyor/export"
 	"github.com/toqueteos/geziyor/metrics"
 	"github.com/toqueteos/geziyor/middleware"
+	"github.com/toqueteos/geziyor/pipeline"
+	"github.com/toqueteos/geziyor/signals"
 )
 
 type ParseFunc func(ctx context.Context, g *Geziyor, r *client.Response)
 type ErrorFunc func(ctx context.Context, g *Geziyor, r *client.Request, err error)
 type StartRequestsFunc func(ctx context.Context, g *Geziyor)
 
+// RequestOption sets optional parameters of scheduled requests
+type RequestOption func(req *ScheduledRequest)
+
+// WithErrback sets the error callback of request. It overrides Options.ErrorFunc for the request.
+// Requests cancelled by middlewares are also passed to errback with client.KindCancelled.
+func WithErrback(errback ErrorFunc) RequestOption {
+	return func(req *ScheduledRequest) {
+		req.Errback = errback
+	}
+}
+
 // Options is custom options type for Geziyor
 type Options struct {
 	// AllowedDomains is domains that are allowed to make requests
 	// If empty, any domain is allowed
 	AllowedDomains []string
 
+	// AutoThrottleEnabled adjusts delays between requests to the same host by their download latency
+	// and backs off on 429/503 responses and timeouts. See middleware.AutoThrottle.
+	// RequestDelay is not applied if it's enabled, it's used as AutoThrottleMinDelay instead.
+	AutoThrottleEnabled bool
+
+	// Maximum delay between requests to the same host for AutoThrottle. Default: 60s
+	AutoThrottleMaxDelay time.Duration
+
+	// Minimum delay between requests to the same host for AutoThrottle. Default: RequestDelay
+	AutoThrottleMinDelay time.Duration
+
+	// Initial delay between requests to the same host for AutoThrottle. Default: 5s
+	AutoThrottleStartDelay time.Duration
+
+	// Average number of requests AutoThrottle tries to process in parallel per host. Default: 1.0
+	AutoThrottleTargetConcurrency float64
+
 	// Chrome headless browser WS endpoint.
 	// If you want to run your own Chrome browser runner, provide its endpoint in here
 	// For example: ws://localhost:3000
@@ -40,10 +72,34 @@ type Options struct {
 	// - RFC2616 policy
 	CachePolicy cache.Policy
 
+	// If true, responses are cached by Fingerprinter of their requests instead of their URLs,
+	// so that requests of the same resource share cache entries.
+	// Enabling it doesn't match entries cached without it.
+	CacheByFingerprint bool
+
+	// Callbacks names callbacks, so that requests saved to JobDir can be restored with their callbacks.
+	// Callbacks must be named functions. Function literals and method values are rejected,
+	// as closures of the same literal can't be told apart.
+	// Requests with unregistered callbacks will use ParseFunc when they're resumed.
+	Callbacks map[string]ParseFunc
+
 	// Response charset detection for decoding to UTF-8
 	CharsetDetectDisabled bool
 
-	// Concurrent requests limit
+	// CloseOnErrorCount stops scraping gracefully after this many request errors. Default: No limit
+	CloseOnErrorCount int
+
+	// CloseOnItemCount stops scraping gracefully after this many exported items. Default: No limit
+	CloseOnItemCount int
+
+	// CloseOnPageCount stops scraping gracefully after this many responses. Default: No limit
+	CloseOnPageCount int
+
+	// CloseOnTimeout stops scraping gracefully after this duration. Default: No limit
+	CloseOnTimeout time.Duration
+
+	// Concurrent requests limit. Also the number of workers processing scheduled requests.
+	// Default: DefaultConcurrentRequests workers, with no extra limit
 	ConcurrentRequests int
 
 	// Concurrent requests per domain limit. Uses request.URL.Host
@@ -53,17 +109,72 @@ type Options struct {
 	// If set true, cookies won't send.
 	CookiesDisabled bool
 
-	// ErrorFunc is callback of errors.
+	// DepthLimit is the maximum depth of requests. Deeper requests are cancelled.
+	// Requests made using contexts of callbacks are one level deeper than their responses.
+	// Default: No limit
+	DepthLimit int
+
+	// DepthPriority adjusts priority of requests by their depth: Priority -= Depth * DepthPriority
+	// Positive values process shallow requests first, negative values process deep requests first.
+	DepthPriority int
+
+	// DomainSettings overrides options for requests to matching hosts. Keys are matched in order:
+	// exact host name ("www.example.com"), suffix ("*.example.com") or registered domain ("example.com").
+	DomainSettings map[string]*DomainSettings
+
+	// DupeFilter stores fingerprints of visited requests.
+	// - dupefilter.Memory (default, or dupefilter.LevelDB in JobDir if it's set)
+	// - dupefilter.Bloom, uses fixed memory with small false positive rate
+	// - dupefilter.LevelDB, exact set on disk
+	DupeFilter dupefilter.DupeFilter
+
+	// Errbacks names error callbacks, so that requests saved to JobDir can be restored with their errbacks.
+	// Like Callbacks, errbacks must be named functions.
+	// Requests with unregistered errbacks will use ErrorFunc when they're resumed.
+	Errbacks map[string]ErrorFunc
+
+	// ErrorFunc is callback of errors. Request errors are of type *client.Error.
 	// If not defined, all errors will be logged.
 	ErrorFunc ErrorFunc
 
 	// For extracting data
 	Exporters []export.Exporter
 
+	// Extensions are connected to Geziyor.Signals to run code on lifecycle events
+	Extensions []signals.Extension
+
+	// FeedParseFunc is callback of feed entries. Entries are in Request.Meta[FeedEntryMeta]. Default: ParseFunc
+	FeedParseFunc ParseFunc
+
+	// FeedSeenFilter stores GUIDs of seen feed entries, so only new entries are requested.
+	// Entries are marked as seen when their responses are received if it implements dupefilter.Checker,
+	// otherwise when they're requested.
+	// Default: dupefilter.Memory, or dupefilter.LevelDB in JobDir if it's set
+	FeedSeenFilter dupefilter.DupeFilter
+
+	// FeedURLs are RSS, Atom or RDF feeds to start from, in addition to StartURLs.
+	// A request is made to FeedParseFunc for each new entry. Feeds are requested even if they're visited in JobDir.
+	FeedURLs []string
+
+	// Fingerprinter identifies same requests for duplicate request filtering and caching.
+	// Default: client.DefaultFingerprinter
+	Fingerprinter client.Fingerprinter
+
+	// FollowMetaKeys are Meta keys copied from responses to requests made by Geziyor.Follow and FollowAll
+	FollowMetaKeys []string
+
+	// ItemPipelines process exported items in order before they are passed to exporters.
+	// Items are dropped if a pipeline returns an error. See pipeline.DropItem
+	ItemPipelines []pipeline.ItemPipeline
+
+	// JobDir is the directory to persist crawl state. (Pending requests, visited URLs and metrics)
+	// Stopped crawls continue from where they left off when started with the same JobDir.
+	JobDir string
+
 	// Disable logging by setting this true
 	LogDisabled bool
 
-	// Max body reading size in bytes. Default: 1GB
+	// Max body reading size in bytes. Larger responses fail with client.ErrBodyTooLarge. Default: 1GB
 	MaxBodySize int64
 
 	// Maximum redirection time. Default: 10
@@ -86,7 +197,7 @@ type Options struct {
 	// If you need to make custom actions in addition to the defaults, use Request.Actions instead of this.
 	PreActions []chromedp.Action
 
-	// Request delays
+	// Delay between requests to the same host. Requests of other hosts are made meanwhile.
 	RequestDelay time.Duration
 
 	// RequestDelayRandomize uses random interval between 0.5 * RequestDelay and 1.5 * RequestDelay
@@ -102,31 +213,109 @@ type Options struct {
 	RequestsPerSecond float64
 
 	// Which HTTP response codes to retry.
-	// Other errors (DNS lookup issues, connections lost, etc) are always retried.
-	// Default: []int{500, 502, 503, 504, 522, 524, 408}
+	// Other errors (DNS lookup issues, connections lost, etc) are retried too.
+	// Default: []int{500, 502, 503, 504, 522, 524, 408, 429}
 	RetryHTTPCodes []int
 
+	// RetryPolicy decides whether and after how long failed requests are retried.
+	// Retries are scheduled again instead of blocking workers, unless the request is Synchronized.
+	// Default: client.BackoffRetryPolicy with RetryTimes
+	RetryPolicy client.RetryPolicy
+
+	// RetryPredicate is called with responses after their body is read.
+	// Responses are retried if it returns true, e.g. to retry captcha pages served with 200 OK.
+	// Request.RetryPredicate overrides this for a request.
+	RetryPredicate func(*client.Response) bool
+
 	// Maximum number of times to retry, in addition to the first download.
 	// Set -1 to disable retrying
 	// Default: 2
 	RetryTimes int
 
+	// If true, requests created from responses with nofollow directive are cancelled.
+	// Directives are read from X-Robots-Tag headers and <meta name="robots"> tags. See client.Response.Robots
+	RobotsMetaNoFollowEnabled bool
+
+	// If true, items exported from responses with noindex directive are dropped.
+	// Items must be exported using Geziyor.Export with contexts of callbacks, items sent to Exports channel aren't checked.
+	// It's applied before ItemPipelines. See pipeline.NoIndex
+	RobotsMetaNoIndexEnabled bool
+
+	// If true, Crawl-delay and Request-rate of robots.txt are ignored
+	RobotsTxtCrawlDelayDisabled bool
+
 	// If true, disable robots.txt checks
 	RobotsTxtDisabled bool
 
+	// How long server and network errors of robots.txt fetches are cached.
+	// Hosts are disallowed during this time. Default: 10m
+	RobotsTxtErrorTTL time.Duration
+
+	// If true, robots.txt crawl delays longer than RobotsTxtMaxCrawlDelay are ignored instead of capped
+	RobotsTxtIgnoreExcessiveCrawlDelay bool
+
+	// Maximum crawl delay accepted from robots.txt. Longer delays are capped to it.
+	// With AutoThrottle, crawl delays are used as minimum delays of hosts.
+	// Default: 60s
+	RobotsTxtMaxCrawlDelay time.Duration
+
+	// RobotsTxtStore keeps fetched robots.txt files across runs.
+	// - middleware.NewMemoryRobotsStore
+	// - middleware.NewCacheRobotsStore, e.g. with the same Cache
+	RobotsTxtStore middleware.RobotsStore
+
+	// How long fetched robots.txt files are used before they're fetched again. Default: 24h
+	RobotsTxtTTL time.Duration
+
+	// User agent token sent with robots.txt requests and matched against its user agent groups.
+	// Default: UserAgent
+	RobotsTxtUserAgent string
+
+	// Rules extract links of responses and request them with their callbacks, see Rule.
+	// They're applied to StartURLs, and to other requests with ParseRules callback.
+	// Responses of StartURLs are passed to ParseFunc and links are extracted from them.
+	Rules []Rule
+
+	// Scheduler decides the order of requests.
+	// - NewPriorityScheduler (default)
+	// - NewFIFOScheduler
+	// - NewLIFOScheduler
+	Scheduler Scheduler
+
+	// If true, alternate language versions of pages given by xhtml:link elements of sitemaps are requested too
+	SitemapAlternateLinks bool
+
+	// SitemapFollow limits sitemaps of sitemap indexes that are followed to the matching ones. Default: All
+	SitemapFollow []*regexp.Regexp
+
+	// Pages and sitemaps whose lastmod is before this are skipped. Entries without lastmod are always requested.
+	SitemapModifiedSince time.Time
+
+	// SitemapRules route pages of sitemaps to callbacks. Pages are passed to the callback of the first matching rule.
+	// Pages not matching any rule are skipped. Default: All pages are passed to ParseFunc
+	SitemapRules []SitemapRule
+
+	// SitemapURLs are sitemaps and sitemap indexes to start from, in addition to StartURLs.
+	// Gzip compressed sitemaps are supported. robots.txt URLs are used to discover sitemaps by their Sitemap lines.
+	SitemapURLs []string
+
 	// StartRequestsFunc called on scraper start
 	StartRequestsFunc StartRequestsFunc
 
 	// First requests will made to this url array. (Concurrently)
 	StartURLs []string
 
-	// Timeout is global request timeout
+	// Timeout is global request timeout, including reading body.
+	// For StreamBody and SaveTo requests, it only limits waiting for response headers.
 	Timeout time.Duration
 
-	// Revisiting same URLs is disabled by default
+	// Revisiting same requests is disabled by default. See Fingerprinter
 	URLRevisitEnabled bool
 
 	// User Agent.
 	// Default: "Geziyor 1.0"
 	UserAgent string
+
+	// A warning is logged when response bodies are larger than this size in bytes. Default: No warning
+	WarnBodySize int64
 }
@@ -0,0 +1,20 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/toqueteos/geziyor/client"
+)
+
+// NoIndex drops items exported from responses with noindex robots directive.
+// Items must be exported with contexts of callbacks, see Geziyor.Export
+type NoIndex struct{}
+
+// ProcessItem drops item if it's exported from a noindex response
+func (NoIndex) ProcessItem(ctx context.Context, item interface{}) (interface{}, error) {
+	if client.RobotsFromContext(ctx).NoIndex {
+		return nil, fmt.Errorf("%w: noindex", DropItem)
+	}
+	return item, nil
+}
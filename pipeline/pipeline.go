@@ -0,0 +1,58 @@
+// Package pipeline processes exported items before they are passed to exporters,
+// to validate, clean, enrich or drop them.
+package pipeline
+
+import (
+	"context"
+	"errors"
+)
+
+// DropItem is returned by item pipelines to drop an item.
+// It can be wrapped to give the reason: fmt.Errorf("%w: missing price", pipeline.DropItem)
+var DropItem = errors.New("item dropped")
+
+// ItemPipeline processes an item and returns the item to be passed to the next pipeline.
+// Returning an error drops the item.
+type ItemPipeline interface {
+	ProcessItem(ctx context.Context, item interface{}) (interface{}, error)
+}
+
+// Opener is implemented by item pipelines that need to acquire resources before scraping is started.
+// If Open fails, scraping is aborted and pipelines opened before it are closed.
+type Opener interface {
+	Open(ctx context.Context) error
+}
+
+// Closer is implemented by item pipelines that need to release resources after all items are processed
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// Namer is implemented by item pipelines to name them in logs, metrics and signals.
+// Type name of the pipeline is used otherwise.
+type Namer interface {
+	Name() string
+}
+
+// Func is an adapter to use functions as item pipelines.
+// Use Named to tell them apart in logs, metrics and signals, they're all named "Func" otherwise.
+type Func func(ctx context.Context, item interface{}) (interface{}, error)
+
+// ProcessItem calls f(ctx, item)
+func (f Func) ProcessItem(ctx context.Context, item interface{}) (interface{}, error) {
+	return f(ctx, item)
+}
+
+// Named returns f as an item pipeline with name
+func Named(name string, f Func) ItemPipeline {
+	return namedFunc{name: name, Func: f}
+}
+
+type namedFunc struct {
+	name string
+	Func
+}
+
+func (f namedFunc) Name() string {
+	return f.name
+}
@@ -0,0 +1,108 @@
+package geziyor_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/export"
+	"github.com/toqueteos/geziyor/pipeline"
+	"github.com/toqueteos/geziyor/signals"
+)
+
+// collector is an exporter that keeps exported items
+type collector struct {
+	items []interface{}
+}
+
+func (c *collector) Export(exports chan interface{}) error {
+	for item := range exports {
+		c.items = append(c.items, item)
+	}
+	return nil
+}
+
+// lifecycle is an item pipeline that records open and close calls
+type lifecycle struct {
+	calls []string
+}
+
+func (l *lifecycle) Open(ctx context.Context) error {
+	l.calls = append(l.calls, "open")
+	return nil
+}
+
+func (l *lifecycle) ProcessItem(ctx context.Context, item interface{}) (interface{}, error) {
+	l.calls = append(l.calls, "process")
+	return item, nil
+}
+
+func (l *lifecycle) Close(ctx context.Context) error {
+	l.calls = append(l.calls, "close")
+	return nil
+}
+
+// failingOpener is an item pipeline that fails to open
+type failingOpener struct{}
+
+func (failingOpener) Open(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func (failingOpener) ProcessItem(ctx context.Context, item interface{}) (interface{}, error) {
+	return item, nil
+}
+
+func TestItemPipelines(t *testing.T) {
+	exporter := &collector{}
+	lc := &lifecycle{}
+	var dropped []string
+	g := geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartRequestsFunc: func(ctx context.Context, g *geziyor.Geziyor) {
+			for i := 0; i < 4; i++ {
+				g.Exports <- i
+			}
+		},
+		ItemPipelines: []pipeline.ItemPipeline{
+			pipeline.Named("odd", func(ctx context.Context, item interface{}) (interface{}, error) {
+				if item.(int)%2 == 1 {
+					return nil, fmt.Errorf("%w: odd number", pipeline.DropItem)
+				}
+				return item, nil
+			}),
+			pipeline.Func(func(ctx context.Context, item interface{}) (interface{}, error) {
+				return item.(int) * 10, nil
+			}),
+			lc,
+		},
+		Exporters:   []export.Exporter{exporter},
+		LogDisabled: true,
+	})
+	g.Signals.Connect(signals.ItemDropped, func(e *signals.Event) {
+		dropped = append(dropped, e.Reason)
+	})
+	g.Start(context.Background())
+
+	assert.Equal(t, []interface{}{0, 20}, exporter.items)
+	assert.Equal(t, []string{"open", "process", "process", "close"}, lc.calls)
+	assert.Equal(t, []string{"odd", "odd"}, dropped)
+}
+
+func TestItemPipelineOpenError(t *testing.T) {
+	lc := &lifecycle{}
+	started := false
+	s := geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartRequestsFunc: func(ctx context.Context, g *geziyor.Geziyor) {
+			started = true
+		},
+		ItemPipelines: []pipeline.ItemPipeline{lc, failingOpener{}},
+		LogDisabled:   true,
+	}).Start(context.Background())
+
+	assert.False(t, started)
+	assert.Equal(t, geziyor.ClosePipelineError, s.CloseReason)
+	assert.Equal(t, []string{"open", "close"}, lc.calls)
+}
@@ -0,0 +1,118 @@
+package geziyor_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+)
+
+func TestRetryScheduled(t *testing.T) {
+	var attempts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	var body string
+	stats := geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartURLs: []string{ts.URL},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			body = string(r.Body)
+		},
+		RetryPolicy:       &client.BackoffRetryPolicy{RetryTimes: 2, BaseDelay: time.Millisecond},
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(context.Background())
+
+	assert.Equal(t, "ok", body)
+	assert.EqualValues(t, 3, attempts.Load())
+	assert.Equal(t, 1, stats.Requests)
+	assert.Equal(t, 2, stats.Retries)
+	assert.Equal(t, 0, stats.Errors)
+}
+
+func TestRetryExhausted(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	var reqErr *client.Error
+	stats := geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartRequestsFunc: func(ctx context.Context, g *geziyor.Geziyor) {
+			req, _ := client.NewRequest(ctx, "GET", ts.URL, nil)
+			req.RetryTimes = 1
+			g.Do(req, nil)
+		},
+		ErrorFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Request, err error) {
+			reqErr, _ = err.(*client.Error)
+		},
+		RetryPolicy:       &client.BackoffRetryPolicy{RetryTimes: 5, BaseDelay: time.Millisecond},
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(context.Background())
+
+	if assert.NotNil(t, reqErr) {
+		assert.Equal(t, client.KindHTTPStatus, reqErr.Kind)
+		assert.Equal(t, http.StatusServiceUnavailable, reqErr.Response.StatusCode)
+	}
+	assert.Equal(t, 1, stats.Retries)
+	assert.Equal(t, 1, stats.Errors)
+}
+
+func TestRetryPredicate(t *testing.T) {
+	var attempts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/blocked" || attempts.Add(1) == 1 {
+			w.Write([]byte("captcha"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	isCaptcha := func(r *client.Response) bool {
+		return string(r.Body) == "captcha"
+	}
+	var bodies []string
+	var lastBody string
+	stats := geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartRequestsFunc: func(ctx context.Context, g *geziyor.Geziyor) {
+			g.Get(ctx, ts.URL, nil)
+			req, _ := client.NewRequest(ctx, "GET", ts.URL+"/blocked", nil)
+			req.RetryPredicate = isCaptcha
+			g.Do(req, nil)
+		},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			bodies = append(bodies, string(r.Body))
+		},
+		ErrorFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Request, err error) {
+			if reqErr, ok := err.(*client.Error); ok && reqErr.Kind == client.KindRetryPredicate {
+				lastBody = string(reqErr.Response.Body)
+			}
+		},
+		RetryPredicate: func(r *client.Response) bool {
+			return r.Request.URL.Path != "/blocked" && isCaptcha(r)
+		},
+		RetryPolicy:        &client.BackoffRetryPolicy{RetryTimes: 2, BaseDelay: time.Millisecond},
+		ConcurrentRequests: 1,
+		RobotsTxtDisabled:  true,
+		LogDisabled:        true,
+	}).Start(context.Background())
+
+	assert.Equal(t, []string{"ok"}, bodies)
+	assert.Equal(t, "captcha", lastBody)
+	assert.Equal(t, 3, stats.Retries)
+}
@@ -0,0 +1,55 @@
+package geziyor_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/export"
+)
+
+func TestRobotsMeta(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><head><meta name="robots" content="nofollow"></head><body>/indexed</body></html>`)
+		case "/header":
+			w.Header().Set("X-Robots-Tag", "noindex")
+			fmt.Fprint(w, `<html><body>/followed</body></html>`)
+		default:
+			fmt.Fprint(w, `<html><body></body></html>`)
+		}
+	}))
+	defer ts.Close()
+
+	var visited []string
+	exporter := &collector{}
+	opt := &geziyor.Options{
+		StartURLs: []string{ts.URL + "/", ts.URL + "/header"},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			visited = append(visited, r.Request.URL.Path)
+			g.Export(ctx, r.Request.URL.Path)
+			if link := r.HTMLDoc.Find("body").Text(); link != "" {
+				g.Get(ctx, ts.URL+link, nil)
+			}
+		},
+		ConcurrentRequests:        1,
+		Exporters:                 []export.Exporter{exporter},
+		RobotsMetaNoFollowEnabled: true,
+		RobotsMetaNoIndexEnabled:  true,
+		RobotsTxtDisabled:         true,
+		LogDisabled:               true,
+	}
+	s := geziyor.NewGeziyor(context.Background(), opt).Start(context.Background())
+
+	assert.ElementsMatch(t, []string{"/", "/header", "/followed"}, visited)
+	assert.ElementsMatch(t, []interface{}{"/", "/followed"}, exporter.items)
+	assert.Equal(t, 1, s.Dropped["MetaRobots"])
+	assert.Empty(t, opt.ItemPipelines)
+}
@@ -0,0 +1,89 @@
+package geziyor
+
+import (
+	"context"
+
+	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/internal"
+	"github.com/toqueteos/geziyor/linkextractor"
+)
+
+// rulesCallback is the JobDir name of ParseRules
+const rulesCallback = "geziyor:rules"
+
+// RuleMeta is the Request.Meta key of the index of Rule that extracted the request
+const RuleMeta = "rule"
+
+// Rule requests links extracted by LinkExtractor with Callback. Nil LinkExtractor extracts all links.
+// If Follow is true, or Callback is nil, Rules are applied to responses of links too.
+type Rule struct {
+	LinkExtractor *linkextractor.LinkExtractor
+	Callback      ParseFunc
+	Follow        bool
+}
+
+// ParseRules is the callback applying Options.Rules to responses.
+// It's the callback of StartURLs if Rules are set, use it to apply Rules to other requests too:
+//
+//	g.Get(ctx, url, geziyor.ParseRules)
+//
+// Responses of requests not extracted by a Rule are passed to Options.ParseFunc, so it can't be ParseFunc itself.
+// Others are passed to Callback of their Rule.
+func ParseRules(ctx context.Context, g *Geziyor, r *client.Response) {
+	rule, ok := g.rule(r.Request)
+	if !ok {
+		if g.Opt.ParseFunc != nil {
+			g.Opt.ParseFunc(ctx, g, r)
+		}
+		g.followRules(ctx, r)
+		return
+	}
+	if rule.Callback != nil {
+		rule.Callback(ctx, g, r)
+	}
+	if rule.Follow || rule.Callback == nil {
+		g.followRules(ctx, r)
+	}
+}
+
+// rule returns the Rule of request. Indexes are decoded as float64 if request is restored from JobDir.
+func (g *Geziyor) rule(req *client.Request) (Rule, bool) {
+	var index int
+	switch v := req.Meta[RuleMeta].(type) {
+	case int:
+		index = v
+	case float64:
+		index = int(v)
+	default:
+		return Rule{}, false
+	}
+	if index < 0 || index >= len(g.Opt.Rules) {
+		return Rule{}, false
+	}
+	return g.Opt.Rules[index], true
+}
+
+// followRules requests links of response extracted by Options.Rules.
+// Links are requested by the first rule extracting them.
+func (g *Geziyor) followRules(ctx context.Context, r *client.Response) {
+	seen := make(map[string]bool)
+	for i, rule := range g.Opt.Rules {
+		extractor := rule.LinkExtractor
+		if extractor == nil {
+			extractor = &linkextractor.LinkExtractor{}
+		}
+		for _, link := range extractor.Extract(r) {
+			if seen[link.URL] {
+				continue
+			}
+			seen[link.URL] = true
+			req, err := client.NewRequest(ctx, "GET", link.URL, nil)
+			if err != nil {
+				internal.Logger.Printf("Request creating error %v\n", err)
+				continue
+			}
+			req.Meta[RuleMeta] = i
+			g.Do(req, ParseRules)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package geziyor_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/linkextractor"
+)
+
+func TestRules(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/category/1">1</a><a href="/category/2">2</a><a href="/about">About</a>`)
+		case "/category/1":
+			fmt.Fprint(w, `<a href="/product/1">P1</a><a href="/category/2">2</a>`)
+		case "/category/2":
+			fmt.Fprint(w, `<a href="/product/2">P2</a>`)
+		case "/product/1", "/product/2":
+			fmt.Fprint(w, `<a href="/product/3">P3</a>`)
+		}
+	}))
+	defer ts.Close()
+
+	var mut sync.Mutex
+	var products []string
+	start := 0
+	geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartURLs: []string{ts.URL + "/"},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			start++
+		},
+		Rules: []geziyor.Rule{
+			{LinkExtractor: &linkextractor.LinkExtractor{Allow: []*regexp.Regexp{regexp.MustCompile(`/category/`)}}},
+			{
+				LinkExtractor: &linkextractor.LinkExtractor{Allow: []*regexp.Regexp{regexp.MustCompile(`/product/`)}},
+				Callback: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+					mut.Lock()
+					defer mut.Unlock()
+					products = append(products, r.Request.URL.Path)
+				},
+			},
+		},
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(context.Background())
+
+	assert.Equal(t, 1, start)
+	// Product pages aren't followed, so /product/3 isn't requested
+	assert.ElementsMatch(t, []string{"/product/1", "/product/2"}, products)
+}
+
+func TestParseRules(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/product/1">P1</a>`)
+		}
+	}))
+	defer ts.Close()
+
+	var products []string
+	geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartRequestsFunc: func(ctx context.Context, g *geziyor.Geziyor) {
+			g.Get(ctx, ts.URL+"/", geziyor.ParseRules)
+		},
+		Rules: []geziyor.Rule{{
+			LinkExtractor: &linkextractor.LinkExtractor{Allow: []*regexp.Regexp{regexp.MustCompile(`/product/`)}},
+			Callback: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+				products = append(products, r.Request.URL.Path)
+			},
+		}},
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(context.Background())
+
+	assert.Equal(t, []string{"/product/1"}, products)
+}
+
+func TestRulesWithoutLinkExtractor(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/page">Page</a>`)
+		}
+	}))
+	defer ts.Close()
+
+	var pages []string
+	geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartURLs: []string{ts.URL + "/"},
+		Rules: []geziyor.Rule{{
+			Callback: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+				pages = append(pages, r.Request.URL.Path)
+			},
+		}},
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(context.Background())
+
+	assert.Equal(t, []string{"/page"}, pages)
+}
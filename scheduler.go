@@ -0,0 +1,176 @@
+package geziyor
+
+import (
+	"container/heap"
+
+	"github.com/toqueteos/geziyor/client"
+)
+
+// ScheduledRequest is a request waiting in the Scheduler along with its callbacks.
+type ScheduledRequest struct {
+	*client.Request
+	Callback ParseFunc
+	Errback  ErrorFunc
+
+	reservation slotReservation
+}
+
+// Scheduler stores requests waiting to be downloaded and decides in which order they're processed.
+// Requests of hosts that are busy or delayed are taken from the scheduler and wait for their hosts
+// in queues of the same kind (see HostScheduler), so that requests of other hosts can be made meanwhile.
+// Implementations don't need to be safe for concurrent use, Geziyor serializes all calls.
+type Scheduler interface {
+	// Enqueue adds a request to the queue
+	Enqueue(req *ScheduledRequest)
+
+	// Next removes and returns the next request to process.
+	// Returns nil if there are no requests left.
+	Next() *ScheduledRequest
+
+	// Len returns the number of requests in the queue
+	Len() int
+}
+
+// HostScheduler is implemented by Schedulers that can create queues of their kind.
+// Requests of busy or delayed hosts wait in these queues, so that they're made in the same order as other requests.
+// Custom Schedulers that don't implement it have requests of busy or delayed hosts made in FIFO order.
+type HostScheduler interface {
+	// NewHostQueue returns a new empty queue for requests of a host
+	NewHostQueue() Scheduler
+}
+
+// FIFOScheduler processes requests in the order they're scheduled. (Breadth-first)
+type FIFOScheduler struct {
+	queue []*ScheduledRequest
+	head  int
+}
+
+// NewFIFOScheduler creates a new first-in first-out scheduler
+func NewFIFOScheduler() *FIFOScheduler {
+	return &FIFOScheduler{}
+}
+
+func (s *FIFOScheduler) Enqueue(req *ScheduledRequest) {
+	s.queue = append(s.queue, req)
+}
+
+func (s *FIFOScheduler) Next() *ScheduledRequest {
+	if s.head == len(s.queue) {
+		return nil
+	}
+	req := s.queue[s.head]
+	s.queue[s.head] = nil
+	s.head++
+
+	// Reclaim consumed space once half of the backing array is unused
+	if s.head > len(s.queue)/2 {
+		s.queue = append(s.queue[:0], s.queue[s.head:]...)
+		s.head = 0
+	}
+	return req
+}
+
+func (s *FIFOScheduler) Len() int {
+	return len(s.queue) - s.head
+}
+
+func (s *FIFOScheduler) NewHostQueue() Scheduler {
+	return NewFIFOScheduler()
+}
+
+// LIFOScheduler processes most recently scheduled requests first. (Depth-first)
+type LIFOScheduler struct {
+	stack []*ScheduledRequest
+}
+
+// NewLIFOScheduler creates a new last-in first-out scheduler
+func NewLIFOScheduler() *LIFOScheduler {
+	return &LIFOScheduler{}
+}
+
+func (s *LIFOScheduler) Enqueue(req *ScheduledRequest) {
+	s.stack = append(s.stack, req)
+}
+
+func (s *LIFOScheduler) Next() *ScheduledRequest {
+	if len(s.stack) == 0 {
+		return nil
+	}
+	last := len(s.stack) - 1
+	req := s.stack[last]
+	s.stack[last] = nil
+	s.stack = s.stack[:last]
+	return req
+}
+
+func (s *LIFOScheduler) Len() int {
+	return len(s.stack)
+}
+
+func (s *LIFOScheduler) NewHostQueue() Scheduler {
+	return NewLIFOScheduler()
+}
+
+// PriorityScheduler processes requests with higher Request.Priority first.
+// Requests with the same priority are processed in the order they're scheduled.
+type PriorityScheduler struct {
+	queue priorityQueue
+	seq   uint64
+}
+
+// NewPriorityScheduler creates a new priority scheduler
+func NewPriorityScheduler() *PriorityScheduler {
+	return &PriorityScheduler{}
+}
+
+func (s *PriorityScheduler) Enqueue(req *ScheduledRequest) {
+	heap.Push(&s.queue, priorityItem{req: req, seq: s.seq})
+	s.seq++
+}
+
+func (s *PriorityScheduler) Next() *ScheduledRequest {
+	if len(s.queue) == 0 {
+		return nil
+	}
+	return heap.Pop(&s.queue).(priorityItem).req
+}
+
+func (s *PriorityScheduler) Len() int {
+	return len(s.queue)
+}
+
+func (s *PriorityScheduler) NewHostQueue() Scheduler {
+	return NewPriorityScheduler()
+}
+
+type priorityItem struct {
+	req *ScheduledRequest
+	seq uint64
+}
+
+// priorityQueue implements heap.Interface
+type priorityQueue []priorityItem
+
+func (q priorityQueue) Len() int { return len(q) }
+
+func (q priorityQueue) Less(i, j int) bool {
+	if q[i].req.Priority != q[j].req.Priority {
+		return q[i].req.Priority > q[j].req.Priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q priorityQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *priorityQueue) Push(x interface{}) {
+	*q = append(*q, x.(priorityItem))
+}
+
+func (q *priorityQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = priorityItem{}
+	*q = old[:n-1]
+	return item
+}
@@ -0,0 +1,207 @@
+package geziyor_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+)
+
+func newScheduledRequest(t *testing.T, url string, priority int) *geziyor.ScheduledRequest {
+	req, err := client.NewRequest(context.Background(), "GET", url, nil)
+	assert.NoError(t, err)
+	req.Priority = priority
+	return &geziyor.ScheduledRequest{Request: req}
+}
+
+func drainScheduler(s geziyor.Scheduler) []string {
+	var urls []string
+	for req := s.Next(); req != nil; req = s.Next() {
+		urls = append(urls, req.URL.Path)
+	}
+	return urls
+}
+
+func TestSchedulers(t *testing.T) {
+	tests := []struct {
+		name      string
+		scheduler geziyor.Scheduler
+		want      []string
+	}{
+		{"FIFO", geziyor.NewFIFOScheduler(), []string{"/a", "/b", "/c", "/d"}},
+		{"LIFO", geziyor.NewLIFOScheduler(), []string{"/d", "/c", "/b", "/a"}},
+		{"Priority", geziyor.NewPriorityScheduler(), []string{"/b", "/d", "/a", "/c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.scheduler.Enqueue(newScheduledRequest(t, "http://localhost/a", 0))
+			tt.scheduler.Enqueue(newScheduledRequest(t, "http://localhost/b", 10))
+			tt.scheduler.Enqueue(newScheduledRequest(t, "http://localhost/c", -1))
+			tt.scheduler.Enqueue(newScheduledRequest(t, "http://localhost/d", 10))
+			assert.Equal(t, 4, tt.scheduler.Len())
+			assert.Equal(t, tt.want, drainScheduler(tt.scheduler))
+			assert.Equal(t, 0, tt.scheduler.Len())
+		})
+	}
+}
+
+func TestSchedulerPriorityOrder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var visited []string
+	ctx := context.Background()
+	geziyor.NewGeziyor(ctx, &geziyor.Options{
+		StartURLs: []string{ts.URL + "/start"},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			// Single worker is busy with this callback, so all requests are queued before any is processed
+			for path, priority := range map[string]int{"/low": -1, "/high": 1, "/normal": 0} {
+				req, _ := client.NewRequest(ctx, "GET", ts.URL+path, nil)
+				req.Priority = priority
+				g.Do(req, func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+					visited = append(visited, r.Request.URL.Path)
+				})
+			}
+		},
+		ConcurrentRequests: 1,
+		RobotsTxtDisabled:  true,
+		LogDisabled:        true,
+	}).Start(ctx)
+
+	assert.Equal(t, []string{"/high", "/normal", "/low"}, visited)
+}
+
+func TestSchedulerPriorityOrderDelayedHost(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var mut sync.Mutex
+	var visited []string
+	ctx := context.Background()
+	visit := func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+		mut.Lock()
+		visited = append(visited, r.Request.URL.Path)
+		mut.Unlock()
+		// Requests of low priority already wait for the host
+		if r.Request.URL.Path == "/low/0" {
+			req, _ := client.NewRequest(ctx, "GET", ts.URL+"/high", nil)
+			req.Priority = 100
+			g.Do(req, nil)
+		}
+	}
+	geziyor.NewGeziyor(ctx, &geziyor.Options{
+		StartRequestsFunc: func(ctx context.Context, g *geziyor.Geziyor) {
+			for i := 0; i < 6; i++ {
+				req, _ := client.NewRequest(ctx, "GET", fmt.Sprintf("%s/low/%d", ts.URL, i), nil)
+				req.Priority = -1
+				g.Do(req, visit)
+			}
+		},
+		ParseFunc:         visit,
+		RequestDelay:      30 * time.Millisecond,
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(ctx)
+
+	assert.Equal(t, []string{"/low/0", "/high", "/low/1", "/low/2", "/low/3", "/low/4", "/low/5"}, visited)
+}
+
+func TestSchedulerBusyHostsDontBlockWorkers(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fast.Close()
+	slowURL, _ := url.Parse(slow.URL)
+	// Domain settings ignore ports, so hosts of test servers are made different
+	fastURL := strings.Replace(fast.URL, "127.0.0.1", "localhost", 1)
+
+	var mut sync.Mutex
+	var fastDone, slowDone time.Duration
+	start := time.Now()
+	geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartRequestsFunc: func(ctx context.Context, g *geziyor.Geziyor) {
+			// Requests of the slow host are scheduled first
+			for i := 0; i < 4; i++ {
+				g.Get(ctx, fmt.Sprintf("%s/%d", slow.URL, i), nil)
+			}
+			for i := 0; i < 4; i++ {
+				g.Get(ctx, fmt.Sprintf("%s/%d", fastURL, i), nil)
+			}
+		},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			mut.Lock()
+			defer mut.Unlock()
+			if r.Request.URL.Host == slowURL.Host {
+				slowDone = time.Since(start)
+			} else {
+				fastDone = time.Since(start)
+			}
+		},
+		DomainSettings: map[string]*geziyor.DomainSettings{
+			slowURL.Hostname(): {ConcurrentRequests: 1, RequestDelay: 50 * time.Millisecond},
+		},
+		ConcurrentRequests: 2,
+		RobotsTxtDisabled:  true,
+		LogDisabled:        true,
+	}).Start(context.Background())
+
+	// Slow host is requested one by one, while the other worker makes requests of the fast host
+	assert.GreaterOrEqual(t, slowDone, 4*100*time.Millisecond)
+	assert.Less(t, fastDone, 200*time.Millisecond)
+}
+
+func TestSchedulerCrawlDelay(t *testing.T) {
+	delayed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nCrawl-delay: 0.1\n")
+		}
+	}))
+	defer delayed.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+
+	var mut sync.Mutex
+	var delayedTimes []time.Time
+	var otherDone time.Duration
+	start := time.Now()
+	geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartRequestsFunc: func(ctx context.Context, g *geziyor.Geziyor) {
+			for i := 0; i < 4; i++ {
+				g.Get(ctx, fmt.Sprintf("%s/%d", delayed.URL, i), nil)
+			}
+			for i := 0; i < 4; i++ {
+				g.Get(ctx, fmt.Sprintf("%s/%d", other.URL, i), nil)
+			}
+		},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			mut.Lock()
+			defer mut.Unlock()
+			if strings.HasPrefix(r.Request.URL.String(), delayed.URL) {
+				delayedTimes = append(delayedTimes, time.Now())
+			} else {
+				otherDone = time.Since(start)
+			}
+		},
+		ConcurrentRequests: 2,
+		LogDisabled:        true,
+	}).Start(context.Background())
+
+	// Crawl delay spaces requests of its host without holding workers
+	if assert.Len(t, delayedTimes, 4) {
+		for i := 1; i < len(delayedTimes); i++ {
+			assert.GreaterOrEqual(t, delayedTimes[i].Sub(delayedTimes[i-1]), 90*time.Millisecond)
+		}
+	}
+	assert.Less(t, otherDone, 100*time.Millisecond)
+}
@@ -0,0 +1,116 @@
+// Package signals provides an event bus to run code on Geziyor's lifecycle events.
+package signals
+
+import (
+	"context"
+	"sync"
+
+	"github.com/toqueteos/geziyor/client"
+)
+
+// Signal is the type of lifecycle events
+type Signal int
+
+const (
+	// SpiderOpened is sent when scraping is started
+	SpiderOpened Signal = iota
+
+	// SpiderClosed is sent when scraping is finished. Event.Reason is the close reason.
+	SpiderClosed
+
+	// SpiderIdle is sent when there are no requests left.
+	// Handlers can schedule more requests to keep scraping alive.
+	SpiderIdle
+
+	// RequestScheduled is sent when a request is scheduled
+	RequestScheduled
+
+	// RequestDropped is sent when a request is cancelled by a middleware.
+	// Event.Reason is the name of the middleware.
+	RequestDropped
+
+	// RequestReachedDownloader is sent when a request passes all request middlewares and is about to be made
+	RequestReachedDownloader
+
+	// ResponseReceived is sent when a response is received and processed by response middlewares
+	ResponseReceived
+
+	// RequestRetried is sent when a failed request is scheduled to be retried. Event.Err is the error.
+	RequestRetried
+
+	// ItemScraped is sent when an item is passed to exporters
+	ItemScraped
+
+	// ItemDropped is sent when an item is dropped by an item pipeline.
+	// Event.Err is the error returned by the pipeline and Event.Reason is the name of the pipeline.
+	ItemDropped
+
+	// ErrorRaised is sent when a request fails or a callback panics
+	ErrorRaised
+)
+
+var signalNames = map[Signal]string{
+	SpiderOpened:             "SpiderOpened",
+	SpiderClosed:             "SpiderClosed",
+	SpiderIdle:               "SpiderIdle",
+	RequestScheduled:         "RequestScheduled",
+	RequestDropped:           "RequestDropped",
+	RequestReachedDownloader: "RequestReachedDownloader",
+	ResponseReceived:         "ResponseReceived",
+	RequestRetried:           "RequestRetried",
+	ItemScraped:              "ItemScraped",
+	ItemDropped:              "ItemDropped",
+	ErrorRaised:              "ErrorRaised",
+}
+
+func (s Signal) String() string {
+	return signalNames[s]
+}
+
+// Event is passed to handlers of signals. Only the fields related to signal are set.
+type Event struct {
+	Signal   Signal
+	Context  context.Context
+	Request  *client.Request
+	Response *client.Response
+	Item     interface{}
+	Err      error
+	Reason   string
+}
+
+// Handler handles events of connected signal
+type Handler func(e *Event)
+
+// Extension connects its handlers to signals of bus
+type Extension interface {
+	Connect(bus *Bus)
+}
+
+// Bus dispatches events to handlers connected to their signals.
+// Handlers run synchronously on the goroutine sending the event, so they should return quickly.
+type Bus struct {
+	mut      sync.RWMutex
+	handlers map[Signal][]Handler
+}
+
+// NewBus creates a new event bus
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[Signal][]Handler)}
+}
+
+// Connect adds handler to be called on signal
+func (b *Bus) Connect(signal Signal, handler Handler) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.handlers[signal] = append(b.handlers[signal], handler)
+}
+
+// Send calls all handlers connected to e.Signal in connection order
+func (b *Bus) Send(e *Event) {
+	b.mut.RLock()
+	handlers := b.handlers[e.Signal]
+	b.mut.RUnlock()
+	for _, handler := range handlers {
+		handler(e)
+	}
+}
@@ -0,0 +1,21 @@
+package signals
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	var calls []string
+	bus.Connect(SpiderOpened, func(e *Event) { calls = append(calls, "first "+e.Signal.String()) })
+	bus.Connect(SpiderOpened, func(e *Event) { calls = append(calls, "second "+e.Signal.String()) })
+	bus.Connect(SpiderClosed, func(e *Event) { calls = append(calls, e.Reason) })
+
+	bus.Send(&Event{Signal: SpiderOpened})
+	bus.Send(&Event{Signal: SpiderIdle})
+	bus.Send(&Event{Signal: SpiderClosed, Reason: "finished"})
+
+	assert.Equal(t, []string{"first SpiderOpened", "second SpiderOpened", "finished"}, calls)
+}
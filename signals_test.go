@@ -0,0 +1,158 @@
+package geziyor_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/signals"
+)
+
+// signalRecorder is an extension that counts sent signals
+type signalRecorder struct {
+	mut    sync.Mutex
+	counts map[signals.Signal]int
+	reason string
+}
+
+func (s *signalRecorder) Connect(bus *signals.Bus) {
+	s.counts = make(map[signals.Signal]int)
+	for sig := signals.SpiderOpened; sig <= signals.ErrorRaised; sig++ {
+		bus.Connect(sig, func(e *signals.Event) {
+			s.mut.Lock()
+			defer s.mut.Unlock()
+			s.counts[e.Signal]++
+			if e.Signal == signals.SpiderClosed {
+				s.reason = e.Reason
+			}
+		})
+	}
+}
+
+func TestSignals(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+
+	recorder := &signalRecorder{}
+	geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartURLs: []string{ts.URL, ts.URL, "http://localhost:0"},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			g.Exports <- string(r.Body)
+		},
+		Extensions:        []signals.Extension{recorder},
+		RetryTimes:        -1,
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	}).Start(context.Background())
+
+	assert.Equal(t, map[signals.Signal]int{
+		signals.SpiderOpened:             1,
+		signals.SpiderIdle:               1,
+		signals.SpiderClosed:             1,
+		signals.RequestScheduled:         3,
+		signals.RequestDropped:           1,
+		signals.RequestReachedDownloader: 2,
+		signals.ResponseReceived:         1,
+		signals.ItemScraped:              1,
+		signals.ErrorRaised:              1,
+	}, recorder.counts)
+	assert.Equal(t, geziyor.CloseFinished, recorder.reason)
+}
+
+func TestSpiderIdleSchedulesRequests(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	var crawled []string
+	g := geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartURLs: []string{ts.URL + "/0"},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			crawled = append(crawled, string(r.Body))
+		},
+		RobotsTxtDisabled: true,
+		LogDisabled:       true,
+	})
+	idle := 0
+	g.Signals.Connect(signals.SpiderIdle, func(e *signals.Event) {
+		idle++
+		if idle < 3 {
+			g.Get(e.Context, fmt.Sprintf("%s/%d", ts.URL, idle), nil)
+		}
+	})
+	g.Start(context.Background())
+
+	assert.Equal(t, []string{"/0", "/1", "/2"}, crawled)
+	assert.Equal(t, 3, idle)
+}
+
+func TestSignalsSynchronizedRetry(t *testing.T) {
+	var attempts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	// Every attempt reaches downloader, so that extensions like AutoThrottle count them symmetrically
+	recorder := &signalRecorder{}
+	geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		StartRequestsFunc: func(ctx context.Context, g *geziyor.Geziyor) {
+			req, _ := client.NewRequest(ctx, "GET", ts.URL, nil)
+			req.Synchronized = true
+			g.Do(req, nil)
+		},
+		Extensions:             []signals.Extension{recorder},
+		AutoThrottleEnabled:    true,
+		AutoThrottleStartDelay: time.Millisecond,
+		RetryPolicy:            &client.BackoffRetryPolicy{RetryTimes: 1, BaseDelay: time.Millisecond},
+		RobotsTxtDisabled:      true,
+		LogDisabled:            true,
+	}).Start(context.Background())
+
+	assert.Equal(t, 2, recorder.counts[signals.RequestReachedDownloader])
+	assert.Equal(t, 1, recorder.counts[signals.RequestRetried])
+	assert.Equal(t, 1, recorder.counts[signals.ResponseReceived])
+}
+
+func TestSignalsForcedShutdown(t *testing.T) {
+	arrived := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	// Requests cancelled by a forced shutdown are still closed with an error, so that AutoThrottle counts them down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-arrived
+		cancel()
+	}()
+	recorder := &signalRecorder{}
+	geziyor.NewGeziyor(ctx, &geziyor.Options{
+		StartURLs:              []string{ts.URL},
+		Extensions:             []signals.Extension{recorder},
+		AutoThrottleEnabled:    true,
+		AutoThrottleStartDelay: time.Millisecond,
+		RobotsTxtDisabled:      true,
+		LogDisabled:            true,
+	}).Start(ctx)
+
+	assert.Equal(t, 1, recorder.counts[signals.RequestReachedDownloader])
+	assert.Equal(t, 1, recorder.counts[signals.ErrorRaised])
+	assert.Equal(t, geziyor.CloseCancelled, recorder.reason)
+}
@@ -0,0 +1,104 @@
+package geziyor
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/toqueteos/geziyor/client"
+	"github.com/toqueteos/geziyor/internal"
+	"github.com/toqueteos/geziyor/sitemap"
+)
+
+// sitemapCallback is the JobDir name of parseSitemap
+const sitemapCallback = "geziyor:sitemap"
+
+// SitemapRule routes pages of sitemaps matching Pattern to Callback
+type SitemapRule struct {
+	Pattern  *regexp.Regexp
+	Callback ParseFunc
+}
+
+// startSitemaps requests Options.SitemapURLs.
+// Sitemaps of robots.txt URLs are discovered from robots.txt middleware.
+func (g *Geziyor) startSitemaps(ctx context.Context) {
+	for _, sitemapURL := range g.Opt.SitemapURLs {
+		if !strings.HasSuffix(sitemapURL, "/robots.txt") {
+			g.Get(ctx, sitemapURL, parseSitemap)
+			continue
+		}
+		req, err := client.NewRequest(ctx, "GET", sitemapURL, nil)
+		if err != nil {
+			internal.Logger.Printf("Request creating error %v\n", err)
+			continue
+		}
+		req.Header.Set("User-Agent", g.Opt.UserAgent)
+		for _, discovered := range g.robots.Sitemaps(req) {
+			g.Get(ctx, discovered, parseSitemap)
+		}
+	}
+}
+
+// parseSitemap is the callback of sitemaps.
+// Sitemaps of indexes are requested recursively, pages are requested with callbacks of matching SitemapRules.
+func parseSitemap(ctx context.Context, g *Geziyor, r *client.Response) {
+	s, err := sitemap.Parse(r.Body)
+	if err != nil {
+		internal.Logger.Printf("Sitemap parsing error %s: %v\n", r.Request.URL.String(), err)
+		return
+	}
+
+	for _, u := range s.URLs {
+		if !g.Opt.SitemapModifiedSince.IsZero() && !u.LastMod.IsZero() && u.LastMod.Before(g.Opt.SitemapModifiedSince) {
+			continue
+		}
+
+		if s.Type == sitemap.Index {
+			if g.followSitemap(u.Loc) {
+				g.Get(ctx, u.Loc, parseSitemap)
+			}
+			continue
+		}
+
+		locs := []string{u.Loc}
+		if g.Opt.SitemapAlternateLinks {
+			for _, alternate := range u.Alternates {
+				if alternate.Href != u.Loc {
+					locs = append(locs, alternate.Href)
+				}
+			}
+		}
+		for _, loc := range locs {
+			if callback, ok := g.sitemapCallback(loc); ok {
+				g.Get(ctx, loc, callback)
+			}
+		}
+	}
+}
+
+// followSitemap reports whether sitemap of an index matches Options.SitemapFollow
+func (g *Geziyor) followSitemap(loc string) bool {
+	if len(g.Opt.SitemapFollow) == 0 {
+		return true
+	}
+	for _, pattern := range g.Opt.SitemapFollow {
+		if pattern.MatchString(loc) {
+			return true
+		}
+	}
+	return false
+}
+
+// sitemapCallback returns callback of the first SitemapRule matching page.
+// Without rules, all pages are passed to Options.ParseFunc.
+func (g *Geziyor) sitemapCallback(loc string) (ParseFunc, bool) {
+	if len(g.Opt.SitemapRules) == 0 {
+		return g.Opt.ParseFunc, true
+	}
+	for _, rule := range g.Opt.SitemapRules {
+		if rule.Pattern.MatchString(loc) {
+			return rule.Callback, true
+		}
+	}
+	return nil, false
+}
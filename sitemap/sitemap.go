@@ -0,0 +1,138 @@
+// Package sitemap parses sitemaps and sitemap indexes of the sitemaps.org protocol.
+// Gzip compressed sitemaps are decompressed transparently.
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MaxSize is the maximum uncompressed size of sitemaps. Sitemaps are limited to 50MB by the protocol.
+const MaxSize = 50 << 20
+
+// ErrTooLarge is returned when uncompressed sitemap is larger than MaxSize
+var ErrTooLarge = errors.New("sitemap too large")
+
+// Type is the type of sitemap
+type Type int
+
+const (
+	// URLSet is a sitemap listing pages
+	URLSet Type = iota
+	// Index is a sitemap index listing other sitemaps
+	Index
+)
+
+// Sitemap is a parsed sitemap or sitemap index
+type Sitemap struct {
+	Type Type
+	// URLs are pages of URLSet, or sitemaps of Index
+	URLs []URL
+}
+
+// URL is an entry of sitemap
+type URL struct {
+	Loc string
+	// LastMod is zero if it's not given or can't be parsed
+	LastMod time.Time
+	// Alternates are alternate language versions of page, given by xhtml:link elements
+	Alternates []Alternate
+}
+
+// Alternate is an alternate language version of a page
+type Alternate struct {
+	Lang string
+	Href string
+}
+
+// xmlURL is the XML form of both url and sitemap elements
+type xmlURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+	Links   []struct {
+		Rel      string `xml:"rel,attr"`
+		HrefLang string `xml:"hreflang,attr"`
+		Href     string `xml:"href,attr"`
+	} `xml:"http://www.w3.org/1999/xhtml link"`
+}
+
+type xmlSitemap struct {
+	XMLName  xml.Name
+	URLs     []xmlURL `xml:"url"`
+	Sitemaps []xmlURL `xml:"sitemap"`
+}
+
+// lastModLayouts are W3C Datetime formats used by lastmod
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// Parse parses sitemap or sitemap index. Gzip compressed data is detected by its magic number.
+func Parse(data []byte) (*Sitemap, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if len(data) > 1 && data[0] == 0x1f && data[1] == 0x8b {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("sitemap gzip: %w", err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+	limited := &io.LimitedReader{R: r, N: MaxSize + 1}
+
+	var s xmlSitemap
+	if err := xml.NewDecoder(limited).Decode(&s); err != nil {
+		if limited.N <= 0 {
+			return nil, ErrTooLarge
+		}
+		return nil, fmt.Errorf("sitemap: %w", err)
+	}
+
+	switch s.XMLName.Local {
+	case "urlset":
+		return &Sitemap{Type: URLSet, URLs: convertURLs(s.URLs)}, nil
+	case "sitemapindex":
+		return &Sitemap{Type: Index, URLs: convertURLs(s.Sitemaps)}, nil
+	default:
+		return nil, fmt.Errorf("sitemap: unknown root element %q", s.XMLName.Local)
+	}
+}
+
+func convertURLs(xmlURLs []xmlURL) []URL {
+	urls := make([]URL, 0, len(xmlURLs))
+	for _, u := range xmlURLs {
+		loc := strings.TrimSpace(u.Loc)
+		if loc == "" {
+			continue
+		}
+		url := URL{Loc: loc, LastMod: parseLastMod(u.LastMod)}
+		for _, link := range u.Links {
+			if link.Rel == "alternate" && link.Href != "" {
+				url.Alternates = append(url.Alternates, Alternate{Lang: link.HrefLang, Href: strings.TrimSpace(link.Href)})
+			}
+		}
+		urls = append(urls, url)
+	}
+	return urls
+}
+
+func parseLastMod(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,53 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const urlSet = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:xhtml="http://www.w3.org/1999/xhtml">
+  <url>
+    <loc> https://example.com/en </loc>
+    <lastmod>2023-04-01</lastmod>
+    <xhtml:link rel="alternate" hreflang="de" href="https://example.com/de"/>
+  </url>
+  <url>
+    <loc>https://example.com/about</loc>
+    <lastmod>2023-04-01T10:30:00+02:00</lastmod>
+  </url>
+</urlset>`
+
+func TestParseURLSet(t *testing.T) {
+	s, err := Parse([]byte(urlSet))
+	assert.NoError(t, err)
+	assert.Equal(t, URLSet, s.Type)
+	assert.Len(t, s.URLs, 2)
+	assert.Equal(t, "https://example.com/en", s.URLs[0].Loc)
+	assert.Equal(t, time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC), s.URLs[0].LastMod)
+	assert.Equal(t, []Alternate{{Lang: "de", Href: "https://example.com/de"}}, s.URLs[0].Alternates)
+	assert.True(t, s.URLs[1].LastMod.Equal(time.Date(2023, 4, 1, 8, 30, 0, 0, time.UTC)))
+}
+
+func TestParseIndexGzip(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/sitemap1.xml.gz</loc></sitemap>
+</sitemapindex>`))
+	w.Close()
+
+	s, err := Parse(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, Index, s.Type)
+	assert.Equal(t, []URL{{Loc: "https://example.com/sitemap1.xml.gz"}}, s.URLs)
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse([]byte("<html><body>Not found</body></html>"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+package geziyor_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+)
+
+func newSitemapServer() *httptest.Server {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nAllow: /\nSitemap: %s/sitemap_index.xml\n", ts.URL)
+		case "/sitemap_index.xml":
+			fmt.Fprintf(w, `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<sitemap><loc>%[1]s/sitemap_products.xml.gz</loc></sitemap>
+<sitemap><loc>%[1]s/sitemap_old.xml</loc><lastmod>2010-01-01</lastmod></sitemap>
+</sitemapindex>`, ts.URL)
+		case "/sitemap_products.xml.gz":
+			var buf bytes.Buffer
+			gw := gzip.NewWriter(&buf)
+			fmt.Fprintf(gw, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:xhtml="http://www.w3.org/1999/xhtml">
+<url><loc>%[1]s/product/1</loc><xhtml:link rel="alternate" hreflang="de" href="%[1]s/de/product/1"/></url>
+<url><loc>%[1]s/product/2</loc><lastmod>2010-01-01</lastmod></url>
+<url><loc>%[1]s/about</loc></url>
+<url><loc>%[1]s/cart</loc></url>
+</urlset>`, ts.URL)
+			gw.Close()
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Write(buf.Bytes())
+		case "/sitemap_old.xml":
+			fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s/old</loc></url></urlset>`, ts.URL)
+		}
+	}))
+	return ts
+}
+
+func TestSitemapURLs(t *testing.T) {
+	ts := newSitemapServer()
+	defer ts.Close()
+
+	var mut sync.Mutex
+	visited := map[string]string{}
+	record := func(kind string) geziyor.ParseFunc {
+		return func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			mut.Lock()
+			defer mut.Unlock()
+			visited[r.Request.URL.Path] = kind
+		}
+	}
+
+	geziyor.NewGeziyor(context.Background(), &geziyor.Options{
+		SitemapURLs:           []string{ts.URL + "/robots.txt"},
+		SitemapAlternateLinks: true,
+		SitemapModifiedSince:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		SitemapRules: []geziyor.SitemapRule{
+			{Pattern: regexp.MustCompile(`/product/`), Callback: record("product")},
+			{Pattern: regexp.MustCompile(`/about$`), Callback: record("page")},
+		},
+		LogDisabled: true,
+	}).Start(context.Background())
+
+	assert.Equal(t, map[string]string{
+		"/product/1":    "product",
+		"/de/product/1": "product",
+		"/about":        "page",
+	}, visited)
+}
@@ -0,0 +1,255 @@
+package geziyor
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/toqueteos/geziyor/internal"
+	"golang.org/x/time/rate"
+)
+
+// slot is the download state of a host.
+// Requests of a host that can't be made yet wait in its slot, so that busy or delayed hosts don't hold workers.
+// They wait in a queue of the same kind as the scheduler, so that they're made in the same order.
+type slot struct {
+	host       string
+	active     int
+	last       time.Time
+	next       time.Time
+	crawlDelay time.Duration
+	probed     bool
+	waiting    Scheduler
+}
+
+// slotReservation is the part of a host slot taken by a request.
+// Reserved delay and rate are given back if request is dropped by a middleware.
+type slotReservation struct {
+	held     bool
+	next     time.Time
+	prevNext time.Time
+	limit    *rate.Reservation
+}
+
+// slotThrottle applies crawl delays of robots.txt to host slots, see middleware.HostThrottle
+type slotThrottle struct {
+	g *Geziyor
+}
+
+// SetMinDelay sets crawl delay of host. Longer delays apply to the time since the last request too.
+func (t slotThrottle) SetMinDelay(host string, delay time.Duration) {
+	g := t.g
+	g.scheduler.Lock()
+	s := g.slot(host)
+	s.crawlDelay = delay
+	if next := s.last.Add(delay); next.After(s.next) {
+		s.next = next
+	}
+	g.scheduler.Unlock()
+
+	if g.autoThrottle != nil {
+		g.autoThrottle.SetMinDelay(host, delay)
+	}
+}
+
+// slot returns slot of host. Must be called with scheduler lock held.
+func (g *Geziyor) slot(host string) *slot {
+	s, exists := g.scheduler.slots[host]
+	if !exists {
+		s = &slot{host: host, waiting: newHostQueue(g.scheduler.queue)}
+		g.scheduler.slots[host] = s
+	}
+	return s
+}
+
+// dispatch returns the first request that can be made now, with its host slot reserved. Returns nil if there isn't any.
+// Waiting requests of hosts are tried before new requests of scheduler, requests that can't be made wait in their slots.
+// Must be called with scheduler lock held.
+func (g *Geziyor) dispatch(now time.Time) *ScheduledRequest {
+	for i, s := range g.scheduler.waiting {
+		reservation, ok := g.reserveSlot(s, now)
+		if !ok {
+			continue
+		}
+		req := s.waiting.Next()
+		req.reservation = reservation
+		if s.waiting.Len() == 0 {
+			g.scheduler.waiting = append(g.scheduler.waiting[:i], g.scheduler.waiting[i+1:]...)
+		}
+		return req
+	}
+
+	for req := g.scheduler.queue.Next(); req != nil; req = g.scheduler.queue.Next() {
+		s := g.slot(req.Host)
+		if s.waiting.Len() == 0 {
+			if reservation, ok := g.reserveSlot(s, now); ok {
+				req.reservation = reservation
+				return req
+			}
+			g.scheduler.waiting = append(g.scheduler.waiting, s)
+		}
+		s.waiting.Enqueue(req)
+	}
+	return nil
+}
+
+// newHostQueue returns a queue for requests waiting for their host, see HostScheduler
+func newHostQueue(queue Scheduler) Scheduler {
+	if hostScheduler, ok := queue.(HostScheduler); ok {
+		return hostScheduler.NewHostQueue()
+	}
+	return NewFIFOScheduler()
+}
+
+// reserveSlot reserves slot for a request if it's not busy or delayed.
+// Until a request of host passes request middlewares, other requests of host wait, so that robots.txt is known.
+// If slot is delayed, a worker is woken up when it's ready. Must be called with scheduler lock held.
+func (g *Geziyor) reserveSlot(s *slot, now time.Time) (slotReservation, bool) {
+	if !s.probed && s.active > 0 {
+		return slotReservation{}, false
+	}
+	dom := g.domains.lookup(s.host)
+	if dom != nil && dom.ConcurrentRequests != 0 {
+		if dom.active >= dom.ConcurrentRequests {
+			return slotReservation{}, false
+		}
+	} else if g.Opt.ConcurrentRequestsPerDomain != 0 && s.active >= g.Opt.ConcurrentRequestsPerDomain {
+		return slotReservation{}, false
+	}
+	if now.Before(s.next) {
+		g.wakeAt(s.next)
+		return slotReservation{}, false
+	}
+
+	limiter := g.rateLimiter
+	if dom != nil && dom.rateLimiter != nil {
+		limiter = dom.rateLimiter
+	}
+	var limit *rate.Reservation
+	if limiter != nil {
+		limit = limiter.ReserveN(now, 1)
+		if !limit.OK() {
+			// Limiter can never allow the request, e.g. a negative rate
+			internal.Logger.Printf("rate limit of %s can't be reserved, request is made without limit\n", s.host)
+			limit = nil
+		} else if delay := limit.DelayFrom(now); delay > 0 {
+			limit.CancelAt(now)
+			g.wakeAt(now.Add(delay))
+			return slotReservation{}, false
+		}
+	}
+
+	s.active++
+	if dom != nil {
+		dom.active++
+	}
+	reservation := slotReservation{held: true, prevNext: s.next, limit: limit}
+	s.last = now
+	s.next = now.Add(g.hostDelay(s, dom))
+	reservation.next = s.next
+	return reservation, true
+}
+
+// waitSlot blocks until slot of synchronized request is reserved
+func (g *Geziyor) waitSlot(req *ScheduledRequest) {
+	g.scheduler.Lock()
+	defer g.scheduler.Unlock()
+	s := g.slot(req.Host)
+	for {
+		if reservation, ok := g.reserveSlot(s, time.Now()); ok {
+			req.reservation = reservation
+			return
+		}
+		g.scheduler.slotFreed.Wait()
+	}
+}
+
+// probeSlot marks host of request as probed, after request passes request middlewares
+func (g *Geziyor) probeSlot(req *ScheduledRequest) {
+	g.scheduler.Lock()
+	s := g.slot(req.Host)
+	probed := s.probed
+	s.probed = true
+	g.scheduler.Unlock()
+	if !probed {
+		g.wake()
+	}
+}
+
+// releaseSlot frees slot of request after it's made. It's a no-op if slot is already released.
+// If request is dropped, its delay and rate are given back unless another request of host is made since.
+func (g *Geziyor) releaseSlot(req *ScheduledRequest, dropped bool) {
+	g.scheduler.Lock()
+	reservation := req.reservation
+	if !reservation.held {
+		g.scheduler.Unlock()
+		return
+	}
+	req.reservation = slotReservation{}
+
+	s := g.slot(req.Host)
+	s.active--
+	s.probed = true
+	if dom := g.domains.lookup(req.Host); dom != nil {
+		dom.active--
+	}
+	if dropped {
+		if s.next.Equal(reservation.next) {
+			s.next = reservation.prevNext
+		}
+		if reservation.limit != nil {
+			reservation.limit.Cancel()
+		}
+	}
+	g.scheduler.Unlock()
+	g.wake()
+}
+
+// hostDelay returns delay before the next request to host: the longest of
+// request delay, crawl delay of robots.txt and AutoThrottle delay of host.
+func (g *Geziyor) hostDelay(s *slot, dom *domain) time.Duration {
+	var delay time.Duration
+	if dom != nil && dom.RequestDelay != 0 {
+		delay = dom.RequestDelay
+	} else if g.autoThrottle == nil {
+		// RequestDelay is the minimum delay of AutoThrottle if it's enabled
+		delay = g.Opt.RequestDelay
+	}
+	if delay > 0 && g.Opt.RequestDelayRandomize {
+		delay = time.Duration((0.5 + rand.Float64()) * float64(delay))
+	}
+	if s.crawlDelay > delay {
+		delay = s.crawlDelay
+	}
+	if g.autoThrottle != nil {
+		if throttleDelay := g.autoThrottle.Delay(s.host); throttleDelay > delay {
+			delay = throttleDelay
+		}
+	}
+	return delay
+}
+
+// wakeAt wakes up a waiting worker at t, when a delayed slot is ready.
+// Only the earliest time is kept, workers set the next one when they're woken up. Must be called with scheduler lock held.
+func (g *Geziyor) wakeAt(t time.Time) {
+	if !g.scheduler.wakeTime.IsZero() && !t.Before(g.scheduler.wakeTime) {
+		return
+	}
+	if g.scheduler.wakeTimer != nil {
+		g.scheduler.wakeTimer.Stop()
+	}
+	g.scheduler.wakeTime = t
+	g.scheduler.wakeTimer = time.AfterFunc(time.Until(t), func() {
+		g.scheduler.Lock()
+		if g.scheduler.wakeTime.Equal(t) {
+			g.scheduler.wakeTime = time.Time{}
+		}
+		g.scheduler.Unlock()
+		g.wake()
+	})
+}
+
+// wake wakes up a waiting worker and synchronized requests waiting for their slots
+func (g *Geziyor) wake() {
+	g.scheduler.cond.Signal()
+	g.scheduler.slotFreed.Broadcast()
+}
@@ -0,0 +1,177 @@
+// Package stats collects crawl statistics to summarize crawls.
+// Unlike metrics, stats are kept in memory and returned when crawl is finished.
+package stats
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/toqueteos/geziyor/cache"
+	"github.com/toqueteos/geziyor/client"
+)
+
+// DomainStats is the statistics of a single domain
+type DomainStats struct {
+	Requests  int   `json:"requests"`
+	Responses int   `json:"responses"`
+	Errors    int   `json:"errors"`
+	Bytes     int64 `json:"bytes"`
+	// Effective crawl delay from robots.txt in seconds
+	CrawlDelay float64 `json:"crawl_delay,omitempty"`
+}
+
+// Stats is the summary of a crawl.
+// Methods are safe for concurrent use, fields should only be read after crawl is finished.
+type Stats struct {
+	mut sync.Mutex
+
+	StartTime   time.Time `json:"start_time"`
+	FinishTime  time.Time `json:"finish_time"`
+	CloseReason string    `json:"close_reason"`
+
+	// Requests sent to server, excluding retries
+	Requests int `json:"requests"`
+	// Responses received
+	Responses int `json:"responses"`
+	// Responses served from cache
+	CachedResponses int `json:"cached_responses"`
+	// Requests failed after all retries
+	Errors int `json:"errors"`
+	// Retries made
+	Retries int `json:"retries"`
+	// Requests cancelled by middlewares, by middleware name
+	Dropped map[string]int `json:"dropped"`
+	// Response body bytes downloaded, excluding streamed bodies
+	Bytes int64 `json:"bytes"`
+	// Exported items
+	Items int `json:"items"`
+
+	// Response counts by status code
+	StatusCodes map[int]int `json:"status_codes"`
+	// Error counts by error kind, see client.ErrorKind
+	ErrorTypes map[string]int `json:"error_types"`
+	// Statistics by domain
+	Domains map[string]*DomainStats `json:"domains"`
+}
+
+// New creates new empty Stats
+func New() *Stats {
+	return &Stats{
+		Dropped:     make(map[string]int),
+		StatusCodes: make(map[int]int),
+		ErrorTypes:  make(map[string]int),
+		Domains:     make(map[string]*DomainStats),
+	}
+}
+
+// domain returns stats of host. Must be called with lock held.
+func (s *Stats) domain(host string) *DomainStats {
+	d, exists := s.Domains[host]
+	if !exists {
+		d = &DomainStats{}
+		s.Domains[host] = d
+	}
+	return d
+}
+
+// Start records the start time
+func (s *Stats) Start() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.StartTime = time.Now()
+}
+
+// Finish records the finish time and close reason
+func (s *Stats) Finish(reason string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.FinishTime = time.Now()
+	s.CloseReason = reason
+}
+
+// RecordRequest records a request that is about to be sent. Retries are not counted.
+func (s *Stats) RecordRequest(req *client.Request) {
+	if req.RetryCount() > 0 {
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.Requests++
+	s.domain(req.Host).Requests++
+}
+
+// RecordDropped records a request cancelled by middleware
+func (s *Stats) RecordDropped(req *client.Request, middleware string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.Dropped[middleware]++
+}
+
+// RecordResponse records a received response
+func (s *Stats) RecordResponse(res *client.Response) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.Responses++
+	s.Retries += res.Request.RetryCount()
+	s.StatusCodes[res.StatusCode]++
+	size := int64(len(res.Body)) + res.SavedSize
+	s.Bytes += size
+	if res.Header.Get(cache.XFromCache) != "" {
+		s.CachedResponses++
+	}
+	d := s.domain(res.Request.Host)
+	d.Responses++
+	d.Bytes += size
+}
+
+// RecordError records a failed request
+func (s *Stats) RecordError(req *client.Request, err error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.Errors++
+	s.Retries += req.RetryCount()
+	s.ErrorTypes[ErrorType(err)]++
+	s.domain(req.Host).Errors++
+}
+
+// RecordCrawlDelay records the effective robots.txt crawl delay of host
+func (s *Stats) RecordCrawlDelay(host string, delay time.Duration) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.domain(host).CrawlDelay = delay.Seconds()
+}
+
+// RecordItem records an exported item
+func (s *Stats) RecordItem() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.Items++
+}
+
+// Elapsed returns the duration of crawl
+func (s *Stats) Elapsed() time.Duration {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.FinishTime.IsZero() {
+		return time.Since(s.StartTime)
+	}
+	return s.FinishTime.Sub(s.StartTime)
+}
+
+// String returns stats as indented JSON
+func (s *Stats) String() string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// ErrorType returns the kind of err, like "dns" or "http_status".
+// Errors that aren't *client.Error are classified like client.NewError does.
+func ErrorType(err error) string {
+	return client.NewError(nil, nil, err).Kind.String()
+}
@@ -0,0 +1,52 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor/client"
+)
+
+func TestErrorType(t *testing.T) {
+	err := fmt.Errorf("response: %w", &net.DNSError{Err: "no such host", Name: "example.invalid"})
+	assert.Equal(t, "dns", ErrorType(err))
+	assert.Equal(t, "timeout", ErrorType(context.DeadlineExceeded))
+	err = &client.Error{Kind: client.KindHTTPStatus, Err: fmt.Errorf("%w %d", client.ErrHTTPStatus, 503)}
+	assert.Equal(t, "http_status", ErrorType(err))
+	assert.Equal(t, "http_status", ErrorType(fmt.Errorf("%w %d", client.ErrHTTPStatus, 503)))
+}
+
+func TestStats(t *testing.T) {
+	s := New()
+	s.Start()
+
+	req, _ := client.NewRequest(context.Background(), "GET", "https://example.com/", nil)
+	s.RecordRequest(req)
+	s.RecordResponse(&client.Response{
+		Response: &http.Response{StatusCode: 200, Header: http.Header{"X-From-Cache": {"1"}}},
+		Body:     []byte("body"),
+		Request:  req,
+	})
+	s.RecordRequest(req)
+	req.RetryCountInc()
+	s.RecordError(req, context.DeadlineExceeded)
+	s.RecordDropped(req, "RobotsTxt")
+	s.RecordItem()
+	s.Finish("finished")
+
+	assert.Equal(t, 2, s.Requests)
+	assert.Equal(t, 1, s.Responses)
+	assert.Equal(t, 1, s.CachedResponses)
+	assert.Equal(t, 1, s.Errors)
+	assert.Equal(t, 1, s.Retries)
+	assert.Equal(t, 1, s.Items)
+	assert.Equal(t, int64(4), s.Bytes)
+	assert.Equal(t, map[int]int{200: 1}, s.StatusCodes)
+	assert.Equal(t, map[string]int{"RobotsTxt": 1}, s.Dropped)
+	assert.Equal(t, &DomainStats{Requests: 2, Responses: 1, Errors: 1, Bytes: 4}, s.Domains["example.com"])
+	assert.Contains(t, s.String(), `"close_reason": "finished"`)
+}
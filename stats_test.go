@@ -0,0 +1,52 @@
+package geziyor_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/toqueteos/geziyor"
+	"github.com/toqueteos/geziyor/client"
+)
+
+func TestStats(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private")
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			fmt.Fprint(w, "page")
+		}
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	s := geziyor.NewGeziyor(ctx, &geziyor.Options{
+		StartURLs: []string{ts.URL + "/"},
+		ParseFunc: func(ctx context.Context, g *geziyor.Geziyor, r *client.Response) {
+			if r.Request.URL.Path == "/" {
+				for _, path := range []string{"/", "/missing", "/private", "/page"} {
+					g.Get(ctx, ts.URL+path, g.Opt.ParseFunc)
+				}
+			}
+			g.Exports <- r.Request.URL.Path
+		},
+		ConcurrentRequests: 1,
+		LogDisabled:        true,
+	}).Start(ctx)
+
+	assert.Equal(t, geziyor.CloseFinished, s.CloseReason)
+	assert.Equal(t, 3, s.Requests)
+	assert.Equal(t, 3, s.Responses)
+	assert.Equal(t, 3, s.Items)
+	assert.Equal(t, map[int]int{200: 2, 404: 1}, s.StatusCodes)
+	assert.Equal(t, map[string]int{"DuplicateRequests": 1, "RobotsTxt": 1}, s.Dropped)
+	assert.Equal(t, int64(8), s.Bytes)
+	assert.Len(t, s.Domains, 1)
+	assert.False(t, s.FinishTime.Before(s.StartTime))
+}